@@ -2,4 +2,4 @@ module github.com/araujoarthur/t2alest
 
 go 1.24.4
 
-require github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
+require github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
@@ -1,14 +1,22 @@
 package repl
 
 import (
+	"cmp"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/araujoarthur/t2alest/tree"
 )
 
-type CommandCallback func(*tree.Tree, ...string) error
+type CommandCallback func(*Session, ...string) error
 type CommandList map[string]Command
 
 type Command struct {
@@ -24,54 +32,152 @@ func (cl CommandList) registerCommand(name string, command Command) {
 func GetCommands() CommandList {
 	newCl := make(CommandList)
 
-	newCl.registerCommand("ping", Command{"type 'ping' and wait for the answer", "this is a test command", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("ping", Command{"type 'ping' and wait for the answer", "this is a test command", func(s *Session, args ...string) error {
 		fmt.Println("pong")
 		return nil
 	}})
 
-	newCl.registerCommand("exit", Command{"no flags are available for this command", "immediately exits the application", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("exit", Command{"no flags are available for this command", "immediately exits the application", func(s *Session, args ...string) error {
+		s.printEvents() // os.Exit skips the deferred calls of the loop
 		os.Exit(0)
 		return nil
 	}})
 
-	newCl.registerCommand("ls", Command{"ls ['PATH']", "lists the content of a directory. If no path is given, it will list the contents of the current directory", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("pwd", Command{"no flags are available for this command", "prints the current working directory", func(s *Session, args ...string) error {
+		fmt.Println(s.cwd)
+		return nil
+	}})
+
+	newCl.registerCommand("cd", Command{"cd ['PATH']", "changes the current working directory to PATH. If no path is given, it goes back to the root", func(s *Session, args ...string) error {
+		if len(args) > 1 {
+			return ERWrongParamCount
+		}
+
+		if len(args) == 0 {
+			return s.chdir("/")
+		}
+
+		return s.chdir(args[0])
+	}})
+
+	newCl.registerCommand("pushd", Command{"pushd ['PATH']", "saves the current directory on the directory stack and changes to PATH. If no path is given, it swaps the current directory with the top of the stack", func(s *Session, args ...string) error {
 		if len(args) > 1 {
 			return ERWrongParamCount
 		}
 
-		var n tree.Node
+		previous := s.cwd
 		if len(args) == 0 {
-			n = t.Root()
+			if len(s.dirStack) == 0 {
+				return ERDirStackEmpty
+			}
+
+			top := len(s.dirStack) - 1
+			if err := s.chdir(s.dirStack[top]); err != nil {
+				return err
+			}
+			s.dirStack[top] = previous
 		} else {
-			var err error
-			n, err = t.FollowPath(args[0])
-			if err != nil {
+			if err := s.chdir(args[0]); err != nil {
 				return err
 			}
+			s.dirStack = append(s.dirStack, previous)
 		}
 
-		if n.IsFile() {
-			return tree.ETIExpectedFolderFoundFile
+		s.printDirStack()
+		return nil
+	}})
+
+	newCl.registerCommand("popd", Command{"no flags are available for this command", "removes the top of the directory stack and changes to it", func(s *Session, args ...string) error {
+		if len(args) != 0 {
+			return ERWrongParamCount
 		}
 
-		nf, err := n.AsFolder()
-		if err != nil {
+		if len(s.dirStack) == 0 {
+			return ERDirStackEmpty
+		}
+
+		top := len(s.dirStack) - 1
+		if err := s.chdir(s.dirStack[top]); err != nil {
 			return err
 		}
 
-		children, err := nf.GetChildren()
+		s.dirStack = s.dirStack[:top]
+		s.printDirStack()
+		return nil
+	}})
+
+	newCl.registerCommand("dirs", Command{"no flags are available for this command", "prints the directory stack, starting by the current directory", func(s *Session, args ...string) error {
+		s.printDirStack()
+		return nil
+	}})
+
+	newCl.registerCommand("ls", Command{"ls [-l] [-P | -L] ['PATH'...]", "lists the content of the directories at the given paths, which can be glob patterns (e.g. 'docs/*.txt'). If no path is given, it will list the contents of the current directory, and paths to files are listed themselves. -l also shows the mode, link count, owner, group and size of every entry. Links are shown as links, with -L they are shown as the node they point to and with -P a link given as PATH is shown itself instead of being listed", func(s *Session, args ...string) error {
+		long, args := popFlag(args, "-l")
+		physical, args := popFlag(args, "-P")
+		logical, args := popFlag(args, "-L")
+		if physical && logical {
+			return ERConflictingFlags
+		}
+
+		args, err := s.expandGlobs(args)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("Files and folders in " + n.Name())
-		for _, child := range children {
-			fmt.Println("\t" + child.Name())
+		if len(args) == 0 {
+			args = []string{s.cwd}
+		}
+
+		printEntry := func(entry tree.Node, name string) {
+			if logical && entry.IsSymlink() {
+				if resolved, err := s.tree.FollowPath(s.nodePath(entry)); err == nil {
+					entry = resolved
+				}
+			}
+
+			if link, err := entry.AsSymlink(); err == nil && long {
+				name += " -> " + link.Target()
+			}
+
+			if !long {
+				fmt.Println("\t" + name)
+				return
+			}
+
+			info := entry.Stat()
+			fmt.Printf("\t%s %2d %-8s %-8s %8d %s\n", info.Mode(), info.Nlink(), info.Owner(), info.Group(), info.Size(), name)
+		}
+
+		for _, arg := range args {
+			target := s.resolve(arg)
+			node, err := s.tree.LfollowPath(target)
+			if err != nil {
+				return err
+			}
+
+			if resolved, err := s.tree.FollowPath(target); err == nil && !(physical && node.IsSymlink()) {
+				node = resolved
+			}
+
+			if !node.IsFolder() {
+				printEntry(node, target)
+				continue
+			}
+
+			children, err := s.tree.ReadDir(target)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Files and folders in " + target)
+			for _, child := range children {
+				printEntry(child, child.Name())
+			}
 		}
 		return nil
 	}})
 
-	newCl.registerCommand("mkdir", Command{"mkdir [-r] 'PATH'", "creates a directory, if the -r flag is present it will create all folders that does not exist in the given path", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("mkdir", Command{"mkdir [-r] 'PATH'", "creates a directory, if the -r flag is present it will create all folders that does not exist in the given path", func(s *Session, args ...string) error {
 		var rec bool = false
 		cont, pos := contains(args, "-r")
 		if cont {
@@ -81,15 +187,15 @@ func GetCommands() CommandList {
 
 		var fullp string
 		if pos < len(args) {
-			fullp = args[pos]
+			fullp = s.resolve(args[pos])
 		} else {
 			return ERNoPath
 		}
 
-		newPathName := filepath.Base(fullp)
-		fullDir := filepath.Dir(fullp)
+		newPathName := path.Base(fullp)
+		fullDir := path.Dir(fullp)
 
-		_, err := t.CreateFolder(fullDir, newPathName, rec)
+		_, err := s.tree.CreateFolder(fullDir, newPathName, rec)
 		if err != nil {
 			return err
 		}
@@ -98,118 +204,1043 @@ func GetCommands() CommandList {
 		return nil
 	}})
 
-	newCl.registerCommand("rm", Command{"rm [-r] 'PATH'", "removes a directory or file in PATH, if PATH is a directory and contains children the command will fail unless the -r flag is present", func(t *tree.Tree, args ...string) error {
-		var rec bool = false
-		cont, pos := contains(args, "-r")
-		if cont {
-			rec = true
-			pos += 1
+	newCl.registerCommand("rm", Command{"rm [-r] 'PATH'...", "removes the directories or files at the given paths, which can be glob patterns (e.g. '*.tmp'). If a path is a directory and contains children the command will fail unless the -r flag is present. A link is removed itself, never what it points to", func(s *Session, args ...string) error {
+		rec, args := popFlag(args, "-r")
+		args, err := s.expandGlobs(args)
+		if err != nil {
+			return err
 		}
 
-		var fullp string
-		if pos < len(args) {
-			fullp = args[pos]
-		} else {
+		if len(args) == 0 {
 			return ERNoPath
 		}
 
-		actualNode, err := t.FollowPath(fullp)
-		if err != nil {
-			return err
+		for _, arg := range args {
+			fullp := s.resolve(arg)
+			actualNode, err := s.tree.LfollowPath(fullp)
+			if err != nil {
+				return err
+			}
+
+			if !actualNode.IsFolder() {
+				err = s.tree.RemoveFile(fullp)
+			} else {
+				err = s.tree.RemoveFolder(fullp, rec)
+			}
+
+			if err != nil {
+				return err
+			}
 		}
 
-		if actualNode.IsFile() {
-			err = t.RemoveFile(fullp)
-		} else {
-			err = t.RemoveFolder(fullp, rec)
+		return nil
+	}})
+
+	newCl.registerCommand("touch", Command{"touch 'PATH'...", "creates an empty file at each of the given paths, or updates its access and modification times if it exists. Paths can be glob patterns (e.g. 'logs/*'), in which case the matching nodes are touched. If any of the directories in a path does not exist this command fails", func(s *Session, args ...string) error {
+		if len(args) < 1 {
+			return ERMissingParams
 		}
 
+		args, err := s.expandGlobs(args)
 		if err != nil {
 			return err
 		}
 
+		for _, arg := range args {
+			fullp := s.resolve(arg)
+			_, err := s.tree.FollowPath(fullp)
+			existed := err == nil
+
+			if err := s.tree.Touch(fullp); err != nil {
+				return err
+			}
+
+			if !existed {
+				fmt.Printf("file '%s' created at '%s'\n", path.Base(fullp), path.Dir(fullp))
+			}
+		}
 		return nil
 	}})
 
-	newCl.registerCommand("touch", Command{"touch 'PATH'", "creates an empty file at PATH. If any of the directories in path does not exist this command fails", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("hash", Command{"hash 'PATH'...", "prints the Merkle hash of the nodes at the given paths, which can be glob patterns. The hash covers the content, permissions, owner and attributes of a node and, for folders, of everything inside it, so two nodes with the same hash are identical copies", func(s *Session, args ...string) error {
 		if len(args) < 1 {
-			return ERMissingParams
+			return ERNoPath
 		}
 
-		directory := filepath.Dir(args[0])
-		base := filepath.Base(args[0])
-		_, err := t.CreateFile(directory, base)
+		paths, err := s.expandGlobs(args)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("file '%s' created at '%s'\n", base, directory)
+		for _, p := range paths {
+			hash, err := s.tree.Hash(s.resolve(p))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s  %s\n", hash, p)
+		}
 		return nil
 	}})
 
-	newCl.registerCommand("find", Command{"find 'NAME'", "looks for a file or directory by 'NAME'", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("stat", Command{"stat [-L] 'PATH'", "prints the type, size, inode, link count, permissions, owner, timestamps and attributes of the node at PATH. A link is described itself unless -L is given, in which case the node it points to is described", func(s *Session, args ...string) error {
+		follow, args := popFlag(args, "-L")
 		if len(args) != 1 {
 			return ERWrongParamCount
 		}
 
-		results, err := t.SearchAll(args[0])
+		lookup := s.tree.LfollowPath
+		if follow {
+			lookup = s.tree.FollowPath
+		}
+
+		node, err := lookup(s.resolve(args[0]))
+		if err != nil {
+			return err
+		}
+
+		info := node.Stat()
+		kind, unit := "file", "bytes"
+		if node.IsFolder() {
+			kind, unit = "folder", "entries"
+		} else if node.IsSymlink() {
+			kind = "symlink"
+		}
+
+		fmt.Printf("  Path: %s\n", s.nodePath(node))
+		fmt.Printf("  Type: %s\n", kind)
+		if link, err := node.AsSymlink(); err == nil {
+			fmt.Printf("Target: %s\n", link.Target())
+		}
+		fmt.Printf("  Size: %d %s\n", info.Size(), unit)
+		fmt.Printf(" Inode: %d\n", info.Ino())
+		fmt.Printf(" Links: %d\n", info.Nlink())
+		fmt.Printf("  Mode: %04o (%s)\n", uint32(info.Mode().Perm()), info.Mode())
+		fmt.Printf(" Owner: %s\n", info.Owner())
+		fmt.Printf(" Group: %s\n", info.Group())
+		fmt.Printf("Modify: %s\n", info.ModTime().Format(time.RFC3339Nano))
+		fmt.Printf("Change: %s\n", info.ChangeTime().Format(time.RFC3339Nano))
+		fmt.Printf("Access: %s\n", info.AccessTime().Format(time.RFC3339Nano))
+
+		attrs := info.Attrs()
+		keys := slices.Sorted(maps.Keys(attrs))
+		for _, k := range keys {
+			fmt.Printf(" Attr.: %s=%s\n", k, attrs[k])
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("setattr", Command{"setattr 'PATH' KEY [VALUE]", "sets the attribute KEY of the node at PATH to VALUE. If no value is given the attribute is removed", func(s *Session, args ...string) error {
+		if len(args) != 2 && len(args) != 3 {
+			return ERWrongParamCount
+		}
+
+		fullp := s.resolve(args[0])
+		if len(args) == 2 {
+			return s.tree.RemoveAttr(fullp, args[1])
+		}
+
+		return s.tree.SetAttr(fullp, args[1], args[2])
+	}})
+
+	newCl.registerCommand("chmod", Command{"chmod MODE 'PATH'", "changes the permissions of the node at PATH. MODE is octal (e.g. 755) or symbolic (e.g. u+x,go-w or a=r). Only the owner of a node can change its mode", func(s *Session, args ...string) error {
+		if len(args) != 2 {
+			return ERWrongParamCount
+		}
+
+		fullp := s.resolve(args[1])
+		node, err := s.tree.FollowPath(fullp)
+		if err != nil {
+			return err
+		}
+
+		mode, err := parseMode(args[0], node.Stat().Mode())
 		if err != nil {
 			return err
 		}
 
-		resultStrings := ""
-		if len(results) > 0 {
-			for _, result := range results {
-				resultStrings = resultStrings + t.EvaluateNodePath(result) + "\n" // RUNTIME PANICS HERE.
+		return s.tree.Chmod(fullp, mode)
+	}})
+
+	newCl.registerCommand("chown", Command{"chown OWNER[:GROUP] 'PATH'", "changes the owner and the group of the node at PATH. Either can be omitted (e.g. ':staff' only changes the group). Only root can change the owner, while owners can give their nodes to one of their groups", func(s *Session, args ...string) error {
+		if len(args) != 2 {
+			return ERWrongParamCount
+		}
+
+		owner, group, _ := strings.Cut(args[0], ":")
+		if owner == "" && group == "" {
+			return ERMissingParams
+		}
+
+		return s.tree.Chown(s.resolve(args[1]), owner, group)
+	}})
+
+	newCl.registerCommand("umask", Command{"umask [MODE]", "prints the umask or, if MODE is given, sets it. The permissions in the umask are removed from every node created afterwards", func(s *Session, args ...string) error {
+		if len(args) > 1 {
+			return ERWrongParamCount
+		}
+
+		if len(args) == 0 {
+			fmt.Printf("%04o\n", uint32(s.tree.Umask()))
+			return nil
+		}
+
+		mask, err := strconv.ParseUint(args[0], 8, 32)
+		if err != nil || mask > 0o777 {
+			return ERInvalidMode
+		}
+
+		s.tree.SetUmask(fs.FileMode(mask))
+		return nil
+	}})
+
+	newCl.registerCommand("su", Command{"su ['USER' ['GROUP'...]]", "runs the next commands as USER, a member of the given groups (the first one being its primary group). If no group is given the user gets a group with its own name. Only root can switch users, and not to root itself: 'su' without a user goes back to the user that ran the last 'su'", func(s *Session, args ...string) error {
+		if len(args) == 0 {
+			if len(s.users) == 0 {
+				return ERNoPreviousUser
 			}
-		} else {
-			return ERNoResults
+
+			s.tree.SetUser(s.users[len(s.users)-1])
+			s.users = s.users[:len(s.users)-1]
+			return nil
 		}
 
-		fmt.Printf("Results (%d):\n", len(results))
-		fmt.Println(resultStrings)
+		current := s.tree.User()
+		if current.Name != tree.RootUser.Name {
+			return ERNotRoot
+		}
+
+		if args[0] == tree.RootUser.Name {
+			return ERSwitchToRoot
+		}
+
+		groups := args[1:]
+		if len(groups) == 0 {
+			groups = []string{args[0]}
+		}
 
+		s.users = append(s.users, current)
+		s.tree.SetUser(tree.User{Name: args[0], Groups: groups})
 		return nil
 	}})
 
-	newCl.registerCommand("strp", Command{"strp", "prints the structured file tree", func(t *tree.Tree, args ...string) error {
-		tree.StructuredPrint(t.Root(), 0)
+	newCl.registerCommand("whoami", Command{"no flags are available for this command", "prints the user the commands run as and its groups", func(s *Session, args ...string) error {
+		user := s.tree.User()
+		fmt.Printf("%s (groups: %s)\n", user.Name, strings.Join(user.Groups, ", "))
 		return nil
 	}})
 
-	newCl.registerCommand("testitf", Command{"testitf ...args", "generic interface to test functions", func(t *tree.Tree, args ...string) error {
-		t.FollowPath("/rashna/foo/boal")
+	newCl.registerCommand("mv", Command{"mv [-f | -n] 'SRC' 'DST'", "moves or renames the node at SRC. If DST is a folder SRC is moved into it. An existing DST is replaced with -f (folders only if empty), kept with -n and reported as an error otherwise", func(s *Session, args ...string) error {
+		force, args := popFlag(args, "-f")
+		noClobber, args := popFlag(args, "-n")
+		if force && noClobber {
+			return ERConflictingFlags
+		}
+
+		if len(args) != 2 {
+			return ERWrongParamCount
+		}
+
+		src, dst := s.resolve(args[0]), s.resolve(args[1])
+		if _, err := s.tree.FollowPath(dst); err != nil && path.Dir(src) == path.Dir(dst) {
+			if err := s.tree.Rename(src, path.Base(dst)); err != nil {
+				return err
+			}
+
+			fmt.Printf("'%s' renamed to '%s'\n", src, dst)
+			return nil
+		}
+
+		move := s.tree.Move
+		if force {
+			move = s.tree.Replace
+		}
+
+		err := move(src, dst)
+		if err == tree.ETIDuplicatedName && noClobber {
+			fmt.Printf("'%s' not moved, destination exists\n", src)
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("'%s' moved to '%s'\n", src, dst)
 		return nil
 	}})
 
-	newCl.registerCommand("graphviz", Command{"graphviz NAME", "saves the current tree in the graphviz format", func(t *tree.Tree, args ...string) error {
-		if len(args) == 0 {
+	newCl.registerCommand("cp", Command{"cp [-r] 'SRC'... 'DST'", "copies the nodes at SRC to DST. Sources can be glob patterns (e.g. 'src/*.go'), and when there is more than one DST must be a folder. If DST is a folder the sources are copied into it. Folders are only copied with -r, along with everything inside them. Copying a file over an existing file replaces its content", func(s *Session, args ...string) error {
+		rec, args := popFlag(args, "-r")
+		if len(args) < 2 {
 			return ERWrongParamCount
 		}
-		graph := "digraph G {\n" + t.Root().GraphVizOutput() + "}"
-		file, err := os.Create(args[0])
+
+		sources, err := s.expandGlobs(args[:len(args)-1])
 		if err != nil {
-			fmt.Println(graph)
 			return err
 		}
 
-		// defines a closure to close the file in a idiomatic and safe way
-		defer func() {
-			if err := file.Close(); err != nil {
-				fmt.Println("ERROR CLOSING THE FILE: ", err)
+		dst := s.resolve(args[len(args)-1])
+		if len(sources) > 1 {
+			dstNode, err := s.tree.FollowPath(dst)
+			if err != nil {
+				return err
 			}
-		}()
 
-		_, err = file.WriteString(graph)
+			if !dstNode.IsFolder() {
+				return tree.ETIExpectedFolderFoundFile
+			}
+		}
+
+		for _, source := range sources {
+			src := s.resolve(source)
+			_, err := s.tree.Copy(src, dst, rec)
+			if err == tree.ETIDuplicatedName {
+				err = s.overwriteFile(src, dst)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("'%s' copied to '%s'\n", src, dst)
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("find", Command{"find [-P | -L] ['PATH'...] [EXPRESSION]", "looks for the files, directories and links under each PATH (the current directory if omitted), the PATHs included, that match EXPRESSION. EXPRESSION is made of the tests -type f|d|l, -name GLOB, -iname GLOB (ignoring case), -regex RE (matching the whole path), -size [+|-]N[k|M|G], -newer 'PATH' and -empty, combined with -and (or -a, which can be left out), -or (or -o), -not (or !) and grouped with ( and ). -mindepth N and -maxdepth N limit how deep the results are. The results are printed, unless -exec COMMAND ARGS... ; is given, in which case COMMAND runs on each of them with {} replaced by their path (-print prints them as well). Links to folders are not descended into, unless -L is given. As before, find ['PATH'] 'NAME' without an expression looks for the nodes named NAME under PATH if NAME is not an existing path", func(s *Session, args ...string) error {
+		_, args = popFlag(args, "-P")
+		follow, args := popFlag(args, "-L")
+
+		query, err := parseFind(s, args)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("file '%s' saved\n", args[0])
+		roots, err := s.expandGlobs(query.roots)
+		if err != nil {
+			return err
+		}
+
+		if len(roots) == 0 {
+			roots = []string{s.cwd}
+		}
+
+		query.options.FollowLinks = follow
+		results := []string{}
+		for _, root := range roots {
+			found, err := s.tree.Find(s.resolve(root), query.options)
+			if err != nil {
+				return err
+			}
+
+			for _, f := range found {
+				results = append(results, f.Path)
+			}
+		}
+
+		if len(results) == 0 {
+			return ERNoResults
+		}
+
+		if query.print {
+			fmt.Printf("Results (%d):\n", len(results))
+			fmt.Println(strings.Join(results, "\n") + "\n")
+		}
+
+		var firstErr error
+		for _, result := range results {
+			for _, exec := range query.execs {
+				if err := s.run(exec, "{}", result); err != nil {
+					fmt.Printf("An error happened running '%s' on '%s': \n%s\n", exec[0], result, err)
+					firstErr = cmp.Or(firstErr, err)
+				}
+			}
+		}
+
+		return firstErr
+	}})
+
+	newCl.registerCommand("ln", Command{"ln [-s] 'TARGET' 'LINK'", "creates LINK as a hard link to the file TARGET, so both names refer to the same content. With -s a symbolic link pointing to TARGET is created instead, which is kept as given (relative targets are resolved from the folder holding the link). If LINK is a folder the link is created inside it, named after TARGET", func(s *Session, args ...string) error {
+		symbolic, args := popFlag(args, "-s")
+		if len(args) != 2 {
+			return ERWrongParamCount
+		}
+
+		target, linkPath := args[0], s.resolve(args[1])
+		if node, err := s.tree.FollowPath(linkPath); err == nil && node.IsFolder() {
+			linkPath = path.Join(linkPath, path.Base(target))
+		}
+
+		if !symbolic {
+			if _, err := s.tree.Link(s.resolve(target), linkPath); err != nil {
+				return err
+			}
+
+			fmt.Printf("'%s' => '%s'\n", linkPath, s.resolve(target))
+			return nil
+		}
+
+		if _, err := s.tree.Symlink(target, linkPath); err != nil {
+			return err
+		}
+
+		fmt.Printf("'%s' -> '%s'\n", linkPath, target)
+		return nil
+	}})
+
+	newCl.registerCommand("readlink", Command{"readlink 'PATH'", "prints the target of the symbolic link at PATH", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		target, err := s.tree.Readlink(s.resolve(args[0]))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(target)
 		return nil
 	}})
 
-	newCl.registerCommand("help", Command{"no flags are available for this command", "prints help about the application commands", func(t *tree.Tree, args ...string) error {
+	newCl.registerCommand("cat", Command{"cat 'PATH' ['PATH'...]", "prints the content of one or more files", func(s *Session, args ...string) error {
+		if len(args) < 1 {
+			return ERNoPath
+		}
+
+		for _, p := range args {
+			content, err := s.tree.ReadFile(s.resolve(p))
+			if err != nil {
+				return err
+			}
+
+			fmt.Print(string(content))
+		}
+
+		return nil
+	}})
+
+	newCl.registerCommand("echo", Command{"echo TEXT [> 'PATH' | >> 'PATH']", "prints TEXT, or writes it to the file at PATH when redirected. '>' replaces the file's content and '>>' appends to it, both create the file if it does not exist", func(s *Session, args ...string) error {
+		redirect, pos := "", len(args)
+		for i, arg := range args {
+			if arg == ">" || arg == ">>" {
+				redirect, pos = arg, i
+				break
+			}
+		}
+
+		text := strings.Join(args[:pos], " ") + "\n"
+		if redirect == "" {
+			fmt.Print(text)
+			return nil
+		}
+
+		if pos+1 >= len(args) {
+			return ERNoPath
+		}
+
+		if pos+2 != len(args) {
+			return ERWrongParamCount
+		}
+
+		if redirect == ">>" {
+			return s.tree.AppendFile(s.resolve(args[pos+1]), []byte(text))
+		}
+
+		return s.tree.WriteFile(s.resolve(args[pos+1]), []byte(text))
+	}})
+
+	newCl.registerCommand("truncate", Command{"truncate [-s SIZE] 'PATH'", "changes the size of the file at PATH to SIZE bytes (0 if omitted). Extra data is discarded and missing bytes are zeroed", func(s *Session, args ...string) error {
+		size := 0
+		cont, pos := contains(args, "-s")
+		if cont {
+			if pos+1 >= len(args) {
+				return ERMissingParams
+			}
+
+			var err error
+			size, err = strconv.Atoi(args[pos+1])
+			if err != nil {
+				return ERInvalidNumber
+			}
+
+			args = append(args[:pos:pos], args[pos+2:]...)
+		}
+
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		if err := s.tree.Truncate(s.resolve(args[0]), size); err != nil {
+			return err
+		}
+
+		fmt.Printf("file '%s' truncated to %d bytes\n", args[0], size)
+		return nil
+	}})
+
+	newCl.registerCommand("undo", Command{"undo [N]", "undoes the last N (1 if omitted) changes made to the tree, newest first. Every command that changes the tree is recorded, see 'history'", func(s *Session, args ...string) error {
+		return s.replayJournal(args, s.tree.Undo, "undone")
+	}})
+
+	newCl.registerCommand("redo", Command{"redo [N]", "makes again the last N (1 if omitted) changes undone by 'undo'. Changes can only be redone until something else changes the tree", func(s *Session, args ...string) error {
+		return s.replayJournal(args, s.tree.Redo, "redone")
+	}})
+
+	newCl.registerCommand("begin", Command{"no flags are available for this command", "opens a transaction: the changes made by the next commands are kept or discarded all at once by 'commit' or 'rollback', and are undone as a single change once committed. They are made on a private copy of the tree, so nobody else sharing the tree (nor the watches) sees them until they are committed. The commit fails, discarding them, if the tree was changed meanwhile", func(s *Session, args ...string) error {
+		tx, err := s.tree.Begin()
+		if err != nil {
+			return err
+		}
+
+		s.beginTransaction(tx)
+		return nil
+	}})
+
+	newCl.registerCommand("commit", Command{"commit [[-m] MESSAGE]", "inside a transaction (see 'begin'), closes it keeping its changes. Otherwise records the current state of the tree as a new commit with MESSAGE on the current branch (see 'log', 'branch', 'checkout' and 'merge'). A transaction must be closed before its changes can be recorded as a commit, so MESSAGE is refused inside one", func(s *Session, args ...string) error {
+		if s.tx != nil {
+			if len(args) != 0 {
+				return tree.ETITransactionOpen
+			}
+			return s.commitTransaction()
+		}
+
+		if len(args) > 0 && args[0] == "-m" {
+			args = args[1:]
+		}
+
+		if len(args) == 0 {
+			return ERWrongParamCount
+		}
+
+		repo := s.tree.Repository()
+		commit, err := repo.Commit(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("[%s %s] %s\n", cmp.Or(repo.CurrentBranch(), "HEAD"), commit.Hash.Short(), commit.Message)
+		return nil
+	}})
+
+	newCl.registerCommand("rollback", Command{"no flags are available for this command", "closes the open transaction discarding its changes", func(s *Session, args ...string) error {
+		if s.tx == nil {
+			return ERNoTransaction
+		}
+
+		ops := s.tx.Operations()
+		if err := s.tx.Rollback(); err != nil {
+			return err
+		}
+
+		s.endTransaction()
+		for _, op := range slices.Backward(ops) {
+			fmt.Printf("rolled back: %s\n", op)
+		}
+
+		s.leaveMissingCwd()
+		return nil
+	}})
+
+	newCl.registerCommand("history", Command{"no flags are available for this command", "prints the changes made to the tree that can be undone, oldest first, followed by the ones that can be redone", func(s *Session, args ...string) error {
+		if len(args) != 0 {
+			return ERWrongParamCount
+		}
+
+		done, undone := s.live().History()
+		if len(done)+len(undone) == 0 {
+			fmt.Println("The history is empty")
+			return nil
+		}
+
+		for i, entry := range done {
+			fmt.Printf("%4d  %s  %s\n", i+1, entry.Time.Format(time.DateTime), entry.Op)
+		}
+
+		for _, entry := range undone {
+			fmt.Printf("%4s  %s  %s (undone)\n", "-", entry.Time.Format(time.DateTime), entry.Op)
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("snapshot", Command{"snapshot NAME", "saves the current state of the tree as the snapshot NAME, replacing the snapshot that already had that name. See 'restore' and 'diff'", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		if err := s.live().Snapshot(args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("snapshot '%s' taken\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("snapshots", Command{"no flags are available for this command", "lists the snapshots of the tree, oldest first", func(s *Session, args ...string) error {
+		if len(args) != 0 {
+			return ERWrongParamCount
+		}
+
+		snapshots := s.live().Snapshots()
+		if len(snapshots) == 0 {
+			fmt.Println("There are no snapshots")
+			return nil
+		}
+
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s  %s\n", snapshot.Time.Format(time.DateTime), snapshot.Name)
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("restore", Command{"restore NAME", "puts the tree back in the state it was when the snapshot NAME was taken. The history is cleared, as its changes cannot be undone anymore", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		if err := s.live().Restore(args[0]); err != nil {
+			return err
+		}
+
+		s.leaveMissingCwd()
+		fmt.Printf("snapshot '%s' restored\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("diff", Command{"diff [-json] SNAP1 [SNAP2]", "prints what changed in the tree from the snapshot SNAP1 to the snapshot SNAP2 (the current tree if omitted): the added (+), removed (-), moved (>) and modified (~) nodes. With -json the changes are printed as a JSON array instead", func(s *Session, args ...string) error {
+		asJSON := len(args) > 0 && args[0] == "-json"
+		if asJSON {
+			args = args[1:]
+		}
+
+		if len(args) < 1 || len(args) > 2 {
+			return ERWrongParamCount
+		}
+
+		older, err := s.live().SnapshotTree(args[0])
+		if err != nil {
+			return err
+		}
+
+		newer, newerName := s.tree, "current tree"
+		if len(args) == 2 {
+			if newer, err = s.live().SnapshotTree(args[1]); err != nil {
+				return err
+			}
+			newerName = args[1]
+		}
+
+		diffs := older.Diff(newer)
+		if asJSON {
+			return printJSONDiff(diffs)
+		}
+
+		printUnifiedDiff(diffs, args[0], newerName)
+		return nil
+	}})
+
+	newCl.registerCommand("log", Command{"log [REV]", "prints the commits reachable from REV (the current commit if omitted), newest first. REV is a branch, HEAD or the beginning of the hash of a commit", func(s *Session, args ...string) error {
+		if len(args) > 1 {
+			return ERWrongParamCount
+		}
+
+		rev := "HEAD"
+		if len(args) == 1 {
+			rev = args[0]
+		}
+
+		repo := s.live().Repository()
+		log, err := repo.Log(rev)
+		if err != nil {
+			return err
+		}
+
+		labels := map[tree.Hash][]string{}
+		head, _ := repo.Head()
+		if repo.CurrentBranch() == "" {
+			labels[head] = append(labels[head], "HEAD")
+		}
+
+		for _, branch := range repo.Branches() {
+			label := branch.Name
+			if branch.Current {
+				label = "HEAD -> " + label
+			}
+			labels[branch.Commit] = append(labels[branch.Commit], label)
+		}
+
+		for _, commit := range log {
+			refs := ""
+			if len(labels[commit.Hash]) > 0 {
+				refs = " (" + strings.Join(labels[commit.Hash], ", ") + ")"
+			}
+			fmt.Printf("%s  %s  %s  %s%s\n", commit.Hash.Short(), commit.Time.Format(time.DateTime), commit.Author, commit.Message, refs)
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("branch", Command{"branch [-d] [NAME [REV]]", "lists the branches, marking the current one with *. Given a NAME, creates the branch NAME at REV (the current commit if omitted), or deletes it with -d", func(s *Session, args ...string) error {
+		repo := s.live().Repository()
+		switch {
+		case len(args) == 0:
+			for _, branch := range repo.Branches() {
+				marker := " "
+				if branch.Current {
+					marker = "*"
+				}
+				fmt.Printf("%s %s  %s\n", marker, branch.Name, branch.Commit.Short())
+			}
+			return nil
+
+		case args[0] == "-d":
+			if len(args) != 2 {
+				return ERWrongParamCount
+			}
+
+			if err := repo.DeleteBranch(args[1]); err != nil {
+				return err
+			}
+
+			fmt.Printf("branch '%s' deleted\n", args[1])
+			return nil
+
+		case len(args) > 2:
+			return ERWrongParamCount
+		}
+
+		rev := ""
+		if len(args) == 2 {
+			rev = args[1]
+		}
+
+		if err := repo.Branch(args[0], rev); err != nil {
+			return err
+		}
+
+		fmt.Printf("branch '%s' created\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("checkout", Command{"checkout [-f] REV", "puts the tree in the state of the commit REV, a branch (which becomes the current one), HEAD or the beginning of the hash of a commit. Uncommitted changes make it fail, unless -f is given to discard them", func(s *Session, args ...string) error {
+		force := len(args) > 0 && args[0] == "-f"
+		if force {
+			args = args[1:]
+		}
+
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		repo := s.live().Repository()
+		if err := repo.Checkout(args[0], force); err != nil {
+			return err
+		}
+
+		s.leaveMissingCwd()
+		if branch := repo.CurrentBranch(); branch != "" {
+			fmt.Printf("switched to branch '%s'\n", branch)
+		} else {
+			head, _ := repo.Head()
+			fmt.Printf("HEAD is now at %s\n", head.Short())
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("merge", Command{"merge REV", "merges the commit REV (usually a branch) into the current branch. Paths changed on both sides in different ways are conflicts: they are listed, and files get both versions between conflict markers. Fix them and run 'commit' to finish the merge", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		result, err := s.live().Repository().Merge(args[0])
+		if err != nil {
+			return err
+		}
+
+		s.leaveMissingCwd()
+		switch {
+		case result.UpToDate:
+			fmt.Println("already up to date")
+		case result.FastForward:
+			fmt.Printf("fast-forward to %s\n", result.Commit.Hash.Short())
+		case len(result.Conflicts) > 0:
+			for _, conflict := range result.Conflicts {
+				fmt.Printf("CONFLICT: %s\n", conflict)
+			}
+			fmt.Println("fix the conflicts and run 'commit' to finish the merge")
+		default:
+			fmt.Printf("[%s] %s\n", result.Commit.Hash.Short(), result.Commit.Message)
+		}
+		return nil
+	}})
+
+	newCl.registerCommand("watch", Command{"watch [-r] ['PATH']", "starts watching PATH: the nodes created, written, removed, renamed or whose mode changes there (or inside it, for a folder) are printed after each command. With -r everything under the folder is watched, not only its direct children. Without PATH, lists the watched paths. See 'unwatch'", func(s *Session, args ...string) error {
+		recursive, args := popFlag(args, "-r")
+		switch {
+		case len(args) > 1:
+			return ERWrongParamCount
+		case len(args) == 0 && recursive:
+			return ERNoPath
+		case len(args) == 0:
+			if len(s.watchers) == 0 {
+				fmt.Println("There are no watches")
+			}
+			for _, w := range s.watchers {
+				mode := ""
+				if w.Recursive() {
+					mode = " (recursive)"
+				}
+				fmt.Printf("%s%s\n", w.Path(), mode)
+			}
+			return nil
+		}
+
+		w, err := s.live().Watch(s.resolve(args[0]), recursive)
+		if err != nil {
+			return err
+		}
+
+		s.watchers = append(s.watchers, w)
+		fmt.Printf("watching '%s'\n", w.Path())
+		return nil
+	}})
+
+	newCl.registerCommand("unwatch", Command{"unwatch ['PATH']", "stops watching PATH, or every watched path if PATH is omitted", func(s *Session, args ...string) error {
+		if len(args) > 1 {
+			return ERWrongParamCount
+		}
+
+		watchers := s.watchers
+		if len(args) == 1 {
+			watchers = slices.DeleteFunc(slices.Clone(watchers), func(w *tree.Watcher) bool { return w.Path() != s.resolve(args[0]) })
+			if len(watchers) == 0 {
+				return ERNotWatched
+			}
+		}
+
+		s.unwatch(watchers)
+		fmt.Printf("%d watch(es) stopped\n", len(watchers))
+		return nil
+	}})
+
+	newCl.registerCommand("strp", Command{"strp", "prints the structured file tree", func(s *Session, args ...string) error {
+		tree.StructuredPrint(s.tree.Root(), 0)
+		return nil
+	}})
+
+	newCl.registerCommand("testitf", Command{"testitf ...args", "generic interface to test functions", func(s *Session, args ...string) error {
+		s.tree.FollowPath("/rashna/foo/boal")
+		return nil
+	}})
+
+	newCl.registerCommand("graphviz", Command{"graphviz [-rankdir TB|LR|BT|RL] [-cluster] [-highlight 'PATH']... NAME", "saves the current tree in the graphviz format to the host file NAME. -cluster draws subfolders as boxes and -highlight (which can be repeated) marks the node at PATH", func(s *Session, args ...string) error {
+		opts := tree.GraphVizOptions{}
+		rest := []string{}
+		for i := 0; i < len(args); i++ {
+			switch args[i] {
+			case "-cluster":
+				opts.Cluster = true
+			case "-rankdir", "-highlight":
+				if i+1 >= len(args) {
+					return ERMissingParams
+				}
+
+				if args[i] == "-rankdir" {
+					opts.RankDir = args[i+1]
+				} else {
+					opts.Highlight = append(opts.Highlight, s.resolve(args[i+1]))
+				}
+				i++
+			default:
+				rest = append(rest, args[i])
+			}
+		}
+
+		if len(rest) != 1 {
+			return ERWrongParamCount
+		}
+		args = rest
+
+		graph, err := s.tree.GraphViz(opts)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			fmt.Println(graph)
+			return err
+		}
+
+		// defines a closure to close the file in a idiomatic and safe way
+		defer func() {
+			if err := file.Close(); err != nil {
+				fmt.Println("ERROR CLOSING THE FILE: ", err)
+			}
+		}()
+
+		_, err = file.WriteString(graph)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("file '%s' saved\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("save", Command{"save FILE", "saves the current tree as JSON to FILE on the host file system", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := file.Close(); err != nil {
+				fmt.Println("ERROR CLOSING THE FILE: ", err)
+			}
+		}()
+
+		if err := s.live().Save(file); err != nil {
+			return err
+		}
+
+		fmt.Printf("tree saved to '%s'\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("load", Command{"load FILE", "replaces the current tree with the one saved as JSON in FILE on the host file system", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		loaded, err := tree.Load(file)
+		if err != nil {
+			return err
+		}
+
+		s.replaceTree(loaded)
+		fmt.Printf("tree loaded from '%s'\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("loadgraphviz", Command{"loadgraphviz FILE", "replaces the current tree with the one described by the graphviz DOT file FILE on the host file system", func(s *Session, args ...string) error {
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		loaded, err := tree.ParseDOT(file)
+		if err != nil {
+			return err
+		}
+
+		s.replaceTree(loaded)
+		fmt.Printf("tree loaded from '%s'\n", args[0])
+		return nil
+	}})
+
+	newCl.registerCommand("import", Command{"import [-content] [-depth N] [-exclude GLOB]... HOSTPATH ['INTO']", "copies the folders and files of the host directory HOSTPATH into the folder INTO (the current directory if omitted). -content also reads the content of the files, -depth limits how deep the import goes and -exclude (which can be repeated) skips entries matching GLOB", func(s *Session, args ...string) error {
+		opts := tree.FromFSOptions{}
+		rest := []string{}
+		for i := 0; i < len(args); i++ {
+			switch args[i] {
+			case "-content":
+				opts.Content = true
+			case "-depth", "-exclude":
+				if i+1 >= len(args) {
+					return ERMissingParams
+				}
+
+				if args[i] == "-exclude" {
+					opts.Exclude = append(opts.Exclude, args[i+1])
+				} else {
+					depth, err := strconv.Atoi(args[i+1])
+					if err != nil || depth < 0 {
+						return ERInvalidNumber
+					}
+					opts.MaxDepth = depth
+				}
+				i++
+			default:
+				rest = append(rest, args[i])
+			}
+		}
+
+		if len(rest) < 1 || len(rest) > 2 {
+			return ERWrongParamCount
+		}
+
+		into := s.cwd
+		if len(rest) == 2 {
+			into = s.resolve(rest[1])
+		}
+
+		hostPath, err := filepath.Abs(rest[0])
+		if err != nil {
+			return err
+		}
+
+		imported, err := tree.FromFS(os.DirFS(filepath.Dir(hostPath)), filepath.Base(hostPath), opts)
+		if err != nil {
+			return err
+		}
+
+		if err := s.tree.Graft(into, imported); err != nil {
+			return err
+		}
+
+		fmt.Printf("'%s' imported into '%s'\n", rest[0], into)
+		return nil
+	}})
+
+	newCl.registerCommand("export", Command{"export [-dry-run] [-f] [-empty] HOSTPATH", "creates the folders and files of the tree inside the host directory HOSTPATH. -dry-run only reports what would be done, -f replaces existing host files and -empty refuses to write into a non-empty directory", func(s *Session, args ...string) error {
+		opts := tree.WriteOptions{}
+		opts.DryRun, args = popFlag(args, "-dry-run")
+		opts.Overwrite, args = popFlag(args, "-f")
+		opts.RequireEmpty, args = popFlag(args, "-empty")
+		if len(args) != 1 {
+			return ERWrongParamCount
+		}
+
+		report, err := s.tree.WriteToDir(args[0], opts)
+		if report != nil {
+			verb := ""
+			if opts.DryRun {
+				verb = "would be "
+			}
+
+			for _, p := range report.Created {
+				fmt.Printf("%screated: %s\n", verb, p)
+			}
+
+			for _, p := range report.Overwritten {
+				fmt.Printf("%soverwritten: %s\n", verb, p)
+			}
+
+			for _, p := range report.Existing {
+				fmt.Printf("already exists: %s\n", p)
+			}
+		}
+
+		return err
+	}})
+
+	newCl.registerCommand("help", Command{"no flags are available for this command", "prints help about the application commands", func(s *Session, args ...string) error {
 		fmt.Printf("-- HELP --\n")
 		for k, v := range newCl {
 			fmt.Printf("%s \t-\t [%s] \t %s\n", k, v.Usage, v.HelpText)
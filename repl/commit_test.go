@@ -0,0 +1,42 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/araujoarthur/t2alest/tree"
+)
+
+func TestCommit(t *testing.T) {
+	s := NewSession(tree.CreateTree())
+	commands := GetCommands()
+	run := func(args ...string) error {
+		t.Helper()
+		return commands[args[0]].Callback(s, args[1:]...)
+	}
+
+	if err := run("commit"); err != ERWrongParamCount {
+		t.Fatalf("expected a message to be required outside a transaction, got %v", err)
+	}
+
+	run("begin")
+	run("mkdir", "a")
+	if err := run("commit", "-m", "first"); err != tree.ETITransactionOpen {
+		t.Fatalf("expected the message to be refused inside a transaction, got %v", err)
+	}
+
+	if err := run("commit"); err != nil || s.tx != nil {
+		t.Fatalf("the transaction was not committed: %v", err)
+	}
+
+	if _, err := s.tree.FollowPath("/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run("commit", "-m", "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if log, err := s.tree.Repository().Log("HEAD"); err != nil || len(log) != 1 || log[0].Message != "first" {
+		t.Fatalf("unexpected log %v (%v)", log, err)
+	}
+}
@@ -0,0 +1,53 @@
+package repl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/araujoarthur/t2alest/tree"
+)
+
+/*
+The marker of each kind of difference in the output of diff, as in a unified diff.
+*/
+var diffMarkers = map[tree.DiffKind]string{
+	tree.DiffAdded:    "+",
+	tree.DiffRemoved:  "-",
+	tree.DiffMoved:    ">",
+	tree.DiffModified: "~",
+}
+
+/*
+Prints diffs in the unified format: a header naming both sides followed by a line per node, marked by the kind of difference and followed by what
+changed in it, if anything.
+*/
+func printUnifiedDiff(diffs []tree.Difference, older string, newer string) {
+	if len(diffs) == 0 {
+		fmt.Println("There are no differences")
+		return
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", older, newer)
+	for _, diff := range diffs {
+		line := diffMarkers[diff.Kind] + " " + diff.Path
+		if diff.Kind == tree.DiffMoved {
+			line = fmt.Sprintf("%s %s -> %s", diffMarkers[diff.Kind], diff.OldPath, diff.Path)
+		}
+
+		if len(diff.Fields) > 0 {
+			line += " (" + strings.Join(diff.Fields, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+}
+
+/*
+Prints diffs as a JSON array, one object per node (see tree.Difference), for other programs to read.
+*/
+func printJSONDiff(diffs []tree.Difference) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(diffs)
+}
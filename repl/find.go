@@ -0,0 +1,267 @@
+package repl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/araujoarthur/t2alest/tree"
+)
+
+/*
+A search as given to find: the folders it starts from, its options and the commands run on its results.
+*/
+type findQuery struct {
+	roots   []string
+	options tree.FindOptions
+	execs   [][]string
+	print   bool
+}
+
+/*
+Parses the arguments of find, which are made of the paths to search (up to the first argument starting an expression) followed by an expression
+built the same way as in find(1):
+
+	-type f|d|l, -name GLOB, -iname GLOB, -regex RE, -size [+|-]N[k|M|G], -newer PATH, -empty
+	EXPR [-a|-and] EXPR, EXPR -o|-or EXPR, !|-not EXPR and ( EXPR )
+
+The options -mindepth N and -maxdepth N and the actions -print and -exec COMMAND ARGS... ; can be given anywhere in the expression. Every {} in
+the arguments of an -exec is replaced by the path of the result it runs on.
+
+The older form find ['PATH'] 'NAME' is still understood: without an expression, if the last of one or two paths does not exist it is taken as
+the exact name of the nodes to look for under the other path (or the current directory), the starting folder excluded.
+*/
+func parseFind(s *Session, args []string) (*findQuery, error) {
+	query := &findQuery{options: tree.FindOptions{MaxDepth: -1}}
+
+	first := len(args)
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") || arg == "(" || arg == "!" {
+			first = i
+			break
+		}
+	}
+	query.roots = args[:first]
+
+	tokens := []string{}
+	for rest := args[first:]; len(rest) > 0; rest = rest[1:] {
+		switch rest[0] {
+		case "-mindepth", "-maxdepth":
+			if len(rest) < 2 {
+				return nil, ERInvalidExpression
+			}
+
+			depth, err := strconv.Atoi(rest[1])
+			if err != nil || depth < 0 {
+				return nil, ERInvalidNumber
+			}
+
+			if rest[0] == "-mindepth" {
+				query.options.MinDepth = depth
+			} else {
+				query.options.MaxDepth = depth
+			}
+			rest = rest[1:]
+
+		case "-print":
+			query.print = true
+
+		case "-exec":
+			end := 1
+			for end < len(rest) && rest[end] != ";" {
+				end++
+			}
+
+			if end == len(rest) || end == 1 {
+				return nil, ERInvalidExpression
+			}
+
+			query.execs = append(query.execs, rest[1:end])
+			rest = rest[end:]
+
+		default:
+			tokens = append(tokens, rest[0])
+		}
+	}
+
+	if last := len(query.roots) - 1; len(tokens) == 0 && (last == 0 || last == 1) {
+		if _, err := s.tree.FollowPath(s.resolve(query.roots[last])); err != nil {
+			query.options.Match = tree.NameIs(query.roots[last])
+			query.options.MinDepth = max(query.options.MinDepth, 1)
+			query.roots = query.roots[:last]
+		}
+	}
+
+	if len(tokens) > 0 {
+		p := &findParser{s: s, tokens: tokens}
+		match, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos < len(p.tokens) {
+			return nil, ERInvalidExpression
+		}
+		query.options.Match = match
+	}
+
+	query.print = query.print || len(query.execs) == 0
+	return query, nil
+}
+
+/*
+Recursive descent parser of find expressions. -or binds looser than -and, which binds looser than -not.
+*/
+type findParser struct {
+	s      *Session
+	tokens []string
+	pos    int
+}
+
+func (p *findParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *findParser) next() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", ERInvalidExpression
+	}
+
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *findParser) or() (tree.Predicate, error) {
+	preds := []tree.Predicate{}
+	for {
+		pred, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+
+		if tok := p.peek(); tok != "-o" && tok != "-or" {
+			return tree.Or(preds...), nil
+		}
+		p.pos++
+	}
+}
+
+func (p *findParser) and() (tree.Predicate, error) {
+	preds := []tree.Predicate{}
+	for {
+		pred, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+
+		switch p.peek() {
+		case "-a", "-and":
+			p.pos++
+		case "", ")", "-o", "-or":
+			return tree.And(preds...), nil
+		}
+	}
+}
+
+func (p *findParser) not() (tree.Predicate, error) {
+	if tok := p.peek(); tok == "!" || tok == "-not" {
+		p.pos++
+		pred, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return tree.Not(pred), nil
+	}
+
+	return p.primary()
+}
+
+func (p *findParser) primary() (tree.Predicate, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok == "(" {
+		pred, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing, err := p.next(); err != nil || closing != ")" {
+			return nil, ERInvalidExpression
+		}
+		return pred, nil
+	}
+
+	if tok == "-empty" {
+		return tree.IsEmpty(), nil
+	}
+
+	arg, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case "-type":
+		if arg != "f" && arg != "d" && arg != "l" {
+			return nil, ERInvalidExpression
+		}
+		return tree.TypeIs(arg[0]), nil
+
+	case "-name", "-iname":
+		return tree.NameMatches(arg, tok == "-iname")
+
+	case "-regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return tree.PathMatches(re), nil
+
+	case "-size":
+		return parseSize(arg)
+
+	case "-newer":
+		ref, err := p.s.tree.FollowPath(p.s.resolve(arg))
+		if err != nil {
+			return nil, err
+		}
+		return tree.NewerThan(ref), nil
+	}
+
+	return nil, ERInvalidExpression
+}
+
+/*
+Parses the argument of -size: a number of bytes, or of kibibytes, mebibytes or gibibytes if followed by k, M or G. A leading + matches the
+bigger sizes and a leading - the smaller ones.
+*/
+func parseSize(spec string) (tree.Predicate, error) {
+	cmp := 0
+	switch {
+	case strings.HasPrefix(spec, "+"):
+		cmp, spec = 1, spec[1:]
+	case strings.HasPrefix(spec, "-"):
+		cmp, spec = -1, spec[1:]
+	}
+
+	unit := int64(1)
+	if suffix := strings.IndexAny(spec, "ckMG"); suffix >= 0 && suffix == len(spec)-1 {
+		unit = map[byte]int64{'c': 1, 'k': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}[spec[suffix]]
+		spec = spec[:suffix]
+	}
+
+	count, err := strconv.ParseInt(spec, 10, 64)
+	if err != nil || count < 0 {
+		return nil, ERInvalidNumber
+	}
+
+	return tree.SizeIs(cmp, count, unit), nil
+}
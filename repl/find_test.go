@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/araujoarthur/t2alest/tree"
+)
+
+/*
+Returns a session over a tree holding /src/a.go (10 bytes), /src/b.txt (2000 bytes), /src/sub/c.go (empty) and the empty folder /docs.
+*/
+func findSession(t *testing.T) *Session {
+	t.Helper()
+	tr := tree.CreateTree()
+	tr.CreateFolder("/", "src", false)
+	tr.WriteFile("/src/a.go", []byte("package a\n"))
+	tr.WriteFile("/src/b.txt", bytes.Repeat([]byte("b"), 2000))
+	tr.CreateFolder("/src", "sub", false)
+	tr.WriteFile("/src/sub/c.go", nil)
+	tr.CreateFolder("/", "docs", false)
+	return NewSession(tr)
+}
+
+/*
+Parses args as find does and returns the paths of the results.
+*/
+func findPaths(t *testing.T, s *Session, args ...string) []string {
+	t.Helper()
+	query, err := parseFind(s, args)
+	if err != nil {
+		t.Fatalf("%q: %v", args, err)
+	}
+
+	roots := query.roots
+	if len(roots) == 0 {
+		roots = []string{s.cwd}
+	}
+
+	paths := []string{}
+	for _, root := range roots {
+		found, err := s.tree.Find(s.resolve(root), query.options)
+		if err != nil {
+			t.Fatalf("%q: %v", args, err)
+		}
+
+		for _, f := range found {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+func TestParseFind(t *testing.T) {
+	s := findSession(t)
+	cases := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"/src", "-name", "*.go", "-o", "-type", "d"}, []string{"/src", "/src/a.go", "/src/sub", "/src/sub/c.go"}},
+		{[]string{"/src", "-type", "f", "-name", "*.go", "-o", "-type", "d"}, []string{"/src", "/src/a.go", "/src/sub", "/src/sub/c.go"}},
+		{[]string{"/src", "-type", "d", "-o", "-type", "f", "-a", "-name", "*.txt"}, []string{"/src", "/src/b.txt", "/src/sub"}},
+		{[]string{"/src", "-type", "f", "-a", "(", "-name", "*.txt", "-o", "-empty", ")"}, []string{"/src/b.txt", "/src/sub/c.go"}},
+		{[]string{"/src", "!", "-type", "d", "-name", "*.go"}, []string{"/src/a.go", "/src/sub/c.go"}},
+		{[]string{"/src", "-not", "(", "-type", "d", "-o", "-name", "*.txt", ")"}, []string{"/src/a.go", "/src/sub/c.go"}},
+		{[]string{"/src", "!", "!", "-name", "b.txt"}, []string{"/src/b.txt"}},
+		{[]string{"/src", "-type", "f", "-size", "+1k"}, []string{"/src/b.txt"}},
+		{[]string{"/src", "-type", "f", "-size", "-1k"}, []string{"/src/sub/c.go"}},
+		{[]string{"/src", "-size", "10c"}, []string{"/src/a.go"}},
+		{[]string{"/src", "-mindepth", "1", "-maxdepth", "1"}, []string{"/src/a.go", "/src/b.txt", "/src/sub"}},
+		{[]string{"/", "-type", "d", "-empty"}, []string{"/docs"}},
+		{[]string{"/src/sub", "/docs"}, []string{"/src/sub", "/src/sub/c.go", "/docs"}},
+		{[]string{"-iname", "A.GO"}, []string{"/src/a.go"}},
+		{[]string{"-regex", `^/src/[ab]\..*$`}, []string{"/src/a.go", "/src/b.txt"}},
+		// the older find ['PATH'] 'NAME' form
+		{[]string{"c.go"}, []string{"/src/sub/c.go"}},
+		{[]string{"/src", "sub"}, []string{"/src/sub"}},
+		{[]string{"/src/sub", "missing"}, []string{}},
+	}
+
+	for _, c := range cases {
+		if got := findPaths(t, s, c.args...); !slices.Equal(got, c.want) {
+			t.Errorf("%q: got %q, want %q", c.args, got, c.want)
+		}
+	}
+}
+
+func TestParseFindActions(t *testing.T) {
+	s := findSession(t)
+	query, err := parseFind(s, []string{"/src", "-name", "*.go", "-exec", "cat", "{}", ";", "-exec", "stat", "{}", ";"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(query.roots, []string{"/src"}) || len(query.execs) != 2 || !slices.Equal(query.execs[0], []string{"cat", "{}"}) || query.print {
+		t.Fatalf("unexpected query %+v", query)
+	}
+
+	if query, _ := parseFind(s, []string{"-exec", "cat", "{}", ";", "-print"}); !query.print {
+		t.Fatal("-print was ignored")
+	}
+}
+
+func TestParseFindErrors(t *testing.T) {
+	s := findSession(t)
+	cases := []struct {
+		args []string
+		want error
+	}{
+		{[]string{"-type", "x"}, ERInvalidExpression},
+		{[]string{"-name"}, ERInvalidExpression},
+		{[]string{"-bogus", "x"}, ERInvalidExpression},
+		{[]string{"(", "-type", "f"}, ERInvalidExpression},
+		{[]string{"-type", "f", ")"}, ERInvalidExpression},
+		{[]string{"(", ")"}, ERInvalidExpression},
+		{[]string{"-type", "f", "-o"}, ERInvalidExpression},
+		{[]string{"!"}, ERInvalidExpression},
+		{[]string{"-size", "abc"}, ERInvalidNumber},
+		{[]string{"-size", "1X"}, ERInvalidNumber},
+		{[]string{"-size", "+-1"}, ERInvalidNumber},
+		{[]string{"-maxdepth", "-1"}, ERInvalidNumber},
+		{[]string{"-mindepth", "x"}, ERInvalidNumber},
+		{[]string{"-maxdepth"}, ERInvalidExpression},
+		{[]string{"-exec", "cat", "{}"}, ERInvalidExpression},
+		{[]string{"-exec", ";"}, ERInvalidExpression},
+		{[]string{"-newer", "/missing"}, tree.ETIPathNotFound},
+	}
+
+	for _, c := range cases {
+		if _, err := parseFind(s, c.args); !errors.Is(err, c.want) {
+			t.Errorf("%q: got %v, want %v", c.args, err, c.want)
+		}
+	}
+
+	if _, err := parseFind(s, []string{"-regex", "("}); err == nil {
+		t.Error("a malformed regular expression was accepted")
+	}
+}
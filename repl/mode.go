@@ -0,0 +1,74 @@
+package repl
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+/*
+Parses a mode as given to chmod, which is either octal (e.g. 755) or a comma separated list of symbolic clauses (e.g. u+x,go-w or a=r). A clause
+is made of the classes it applies to (u, g, o or a, all of them if omitted), an operator (+ adds, - removes and = sets) and the permissions (r, w
+and x). Symbolic modes are applied on top of current.
+*/
+func parseMode(spec string, current fs.FileMode) (fs.FileMode, error) {
+	if octal, err := strconv.ParseUint(spec, 8, 32); err == nil {
+		if octal > uint64(fs.ModePerm) {
+			return 0, ERInvalidMode
+		}
+		return fs.FileMode(octal), nil
+	}
+
+	mode := current.Perm()
+	for _, clause := range strings.Split(spec, ",") {
+		op := strings.IndexAny(clause, "+-=")
+		if op < 0 {
+			return 0, ERInvalidMode
+		}
+
+		var who fs.FileMode
+		for _, c := range clause[:op] {
+			switch c {
+			case 'u':
+				who |= 0o700
+			case 'g':
+				who |= 0o070
+			case 'o':
+				who |= 0o007
+			case 'a':
+				who |= 0o777
+			default:
+				return 0, ERInvalidMode
+			}
+		}
+
+		if who == 0 {
+			who = 0o777
+		}
+
+		var perm fs.FileMode
+		for _, c := range clause[op+1:] {
+			switch c {
+			case 'r':
+				perm |= 0o444
+			case 'w':
+				perm |= 0o222
+			case 'x':
+				perm |= 0o111
+			default:
+				return 0, ERInvalidMode
+			}
+		}
+
+		switch clause[op] {
+		case '+':
+			mode |= who & perm
+		case '-':
+			mode &^= who & perm
+		case '=':
+			mode = mode&^who | who&perm
+		}
+	}
+
+	return mode, nil
+}
@@ -0,0 +1,43 @@
+package repl
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	cases := []struct {
+		spec    string
+		current fs.FileMode
+		want    fs.FileMode
+	}{
+		{"755", 0o644, 0o755},
+		{"0600", 0o777, 0o600},
+		{"0", 0o777, 0},
+		{"777", 0, 0o777},
+		{"u+x", 0o644, 0o744},
+		{"go-w", 0o777, 0o755},
+		{"u+x,go-w", 0o666, 0o744},
+		{"a=r", 0o755, 0o444},
+		{"=rx", 0o600, 0o555},
+		{"+w", 0o444, 0o666},
+		{"o=", 0o757, 0o750},
+		{"ug=rw,o-rwx", 0o777, 0o660},
+		{"u-x", fs.ModeDir | 0o755, 0o655},
+		{"g+", 0o640, 0o640},
+	}
+
+	for _, c := range cases {
+		if got, err := parseMode(c.spec, c.current); err != nil || got != c.want {
+			t.Errorf("%q on %o: got %o (%v), want %o", c.spec, c.current, got, err, c.want)
+		}
+	}
+}
+
+func TestParseModeErrors(t *testing.T) {
+	for _, spec := range []string{"", "1000", "789", "-1", "u", "rwx", "x+r", "u+z", "u+x,", "u+x,,g-w", "u+x go-w"} {
+		if _, err := parseMode(spec, 0o644); err != ERInvalidMode {
+			t.Errorf("%q: got %v, want ERInvalidMode", spec, err)
+		}
+	}
+}
@@ -14,16 +14,27 @@ func REPLStartLoop() {
 	commands := GetCommands()
 
 	fmt.Println("Welcome to T2Alest (R)ead-(E)val-(P)rint (L)oop")
-	fmt.Println("Remember: All paths are presumed to be relative to root (./)")
+	fmt.Println("Remember: Paths are relative to the current directory (see 'cd' and 'pwd'), absolute paths start with /")
 
-	t := tree.CreateTree()
+	session := NewSession(tree.CreateTree())
+	defer session.printEvents() // what happened after the last command, before the input ended
 
 	scanner := bufio.NewScanner(os.Stdin)
 
 	for {
-		fmt.Print("> ")
-		scanner.Scan()
+		if session.tx != nil {
+			fmt.Print("(transaction) ")
+		}
+		fmt.Printf("%s > ", session.cwd)
+		if !scanner.Scan() {
+			break
+		}
+
 		input := sanitizer(scanner.Text())
+		if len(input) == 0 {
+			continue
+		}
+
 		command, ok := commands[input[0]]
 
 		if !ok {
@@ -34,19 +45,27 @@ func REPLStartLoop() {
 			continue
 		}
 
-		err := command.Callback(t, input[1:]...)
+		err := command.Callback(session, input[1:]...)
 		if err != nil {
 			fmt.Printf("An error happened: \n%s\n", err)
 		}
+		session.printEvents()
 	}
 }
 
+/*
+Splits the input the way a shell would. Only the command name is lowercased, so the arguments (e.g. the text given to echo) keep their original case.
+*/
 func sanitizer(t string) []string {
-	separated, err := shlex.Split(strings.ToLower(t))
+	separated, err := shlex.Split(t)
 	if err != nil {
 		panic("should have no errors in shlex!")
 	}
 
+	if len(separated) > 0 {
+		separated[0] = strings.ToLower(separated[0])
+	}
+
 	return separated
 }
 
@@ -58,3 +77,20 @@ func contains(slice []string, val string) (bool, int) {
 	}
 	return false, 0
 }
+
+/*
+Removes every occurrence of flag from args, returning whether it was present and the remaining arguments.
+*/
+func popFlag(args []string, flag string) (bool, []string) {
+	found := false
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return found, rest
+}
@@ -5,10 +5,21 @@ import (
 )
 
 var (
-	ERNoPath          = RErrorNew(1, "path is needed but was not found")
-	ERMissingParams   = RErrorNew(2, "there are missing parameters") // generic error for missing parameters
-	ERWrongParamCount = RErrorNew(3, "wrong parameter count")
-	ERNoResults       = RErrorNew(4, "the current search yielded no results")
+	ERNoPath            = RErrorNew(1, "path is needed but was not found")
+	ERMissingParams     = RErrorNew(2, "there are missing parameters") // generic error for missing parameters
+	ERWrongParamCount   = RErrorNew(3, "wrong parameter count")
+	ERNoResults         = RErrorNew(4, "the current search yielded no results")
+	ERInvalidNumber     = RErrorNew(5, "expected a valid number")
+	ERDirStackEmpty     = RErrorNew(6, "the directory stack is empty")
+	ERConflictingFlags  = RErrorNew(7, "the given flags cannot be used together")
+	ERInvalidMode       = RErrorNew(8, "expected an octal (e.g. 755) or symbolic (e.g. u+x,go-w) mode")
+	ERInvalidExpression = RErrorNew(9, "the expression is malformed")
+	ERUnknownCommand    = RErrorNew(10, "the command does not exist")
+	ERNoTransaction     = RErrorNew(11, "there is no open transaction")
+	ERNotWatched        = RErrorNew(12, "the path is not being watched")
+	ERNotRoot           = RErrorNew(13, "only root can switch to another user")
+	ERSwitchToRoot      = RErrorNew(14, "cannot switch to root, use 'su' without a user to go back")
+	ERNoPreviousUser    = RErrorNew(15, "there is no previous user to go back to")
 )
 
 type ERepl struct {
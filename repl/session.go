@@ -0,0 +1,286 @@
+package repl
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/araujoarthur/t2alest/tree"
+)
+
+/*
+Session holds the state of a REPL session that does not belong to the tree itself, such as the current working directory, the
+directory stack used by pushd and popd, the users left by su, the transaction opened by begin and the watches started by watch. Every command
+receives the session it is running on. While a transaction is open, the commands change its working copy (see tree.Transaction) while the
+journal, the snapshots, the repository and the watches are still the ones of the tree the transaction was opened on.
+*/
+type Session struct {
+	tree     *tree.Tree
+	cwd      string
+	dirStack []string
+	users    []tree.User // the users su switched from, the last one being restored by su without a user
+	tx       *tree.Transaction
+	base     *tree.Tree // the tree tx was opened on, tree being its working copy until tx is closed
+	watchers []*tree.Watcher
+}
+
+/*
+Creates a session over t with the working directory set to the root.
+*/
+func NewSession(t *tree.Tree) *Session {
+	return &Session{
+		tree:     t,
+		cwd:      "/",
+		dirStack: []string{},
+	}
+}
+
+/*
+Makes t the tree of the session. Since the old directories may not exist in the new tree, the working directory goes back to the root and the
+directory stack is cleared. The user and the umask of the session carry over to the new tree, while an open transaction and the watches are
+dropped along with the old tree.
+*/
+func (s *Session) replaceTree(t *tree.Tree) {
+	if s.tx != nil {
+		s.tx.Rollback()
+		s.endTransaction()
+	}
+
+	s.unwatch(s.watchers)
+	t.SetUser(s.tree.User())
+	t.SetUmask(s.tree.Umask())
+	s.tree = t
+	s.cwd = "/"
+	s.dirStack = []string{}
+}
+
+/*
+Makes the working copy of tx the tree of the session, until endTransaction.
+*/
+func (s *Session) beginTransaction(tx *tree.Transaction) {
+	s.tx, s.base = tx, s.tree
+	s.tree = tx.Tree()
+}
+
+/*
+Gives the session back the tree the transaction was opened on, once it is closed. The user and the umask set meanwhile carry over to it.
+*/
+func (s *Session) endTransaction() {
+	s.base.SetUser(s.tree.User())
+	s.base.SetUmask(s.tree.Umask())
+	s.tree, s.base, s.tx = s.base, nil, nil
+}
+
+/*
+Commits the open transaction and gives the session back the tree it was opened on, printing how many changes were applied to it.
+*/
+func (s *Session) commitTransaction() error {
+	ops := len(s.tx.Operations())
+	err := s.tx.Commit()
+	s.endTransaction() // even a failed commit closes the transaction
+	if err != nil {
+		s.leaveMissingCwd()
+		return err
+	}
+
+	fmt.Printf("committed %d change(s)\n", ops)
+	return nil
+}
+
+/*
+Returns the tree the session is built on: the one the open transaction was opened on, if any, the tree of the session otherwise. Its journal,
+snapshots, repository and watches are the ones of the session.
+*/
+func (s *Session) live() *tree.Tree {
+	if s.tx != nil {
+		return s.base
+	}
+	return s.tree
+}
+
+/*
+Stops the given watches of the session.
+*/
+func (s *Session) unwatch(watchers []*tree.Watcher) {
+	for _, w := range watchers {
+		w.Close()
+	}
+
+	s.watchers = slices.DeleteFunc(s.watchers, func(w *tree.Watcher) bool { return slices.Contains(watchers, w) })
+}
+
+/*
+Prints the events received by the watches of the session, each one after the path it was reported for. Called after every command, so the
+changes made by the command are shown right after it, along with the ones made meanwhile by anyone else sharing the tree.
+*/
+func (s *Session) printEvents() {
+	for _, w := range s.watchers {
+		for pending := true; pending; {
+			select {
+			case e := <-w.Events:
+				fmt.Printf("[watch %s] %s\n", w.Path(), e)
+			case err := <-w.Errors:
+				fmt.Printf("[watch %s] %s\n", w.Path(), err)
+			default:
+				pending = false
+			}
+		}
+	}
+}
+
+/*
+Resolves p against the current working directory, returning a clean absolute path. Absolute paths (starting with /) are only cleaned.
+*/
+func (s *Session) resolve(p string) string {
+	p = filepath.ToSlash(p)
+	if strings.HasPrefix(p, "/") {
+		return path.Clean(p)
+	}
+
+	return path.Join(s.cwd, p)
+}
+
+/*
+Expands the glob patterns in args (see tree.Glob) into the absolute paths they match, as a shell does before running a command. Flags, arguments
+without patterns and patterns matching nothing are kept as they are.
+*/
+func (s *Session) expandGlobs(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || !tree.HasGlobMeta(arg) {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		matches, err := s.tree.Glob(s.resolve(arg))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			matches = []string{arg}
+		}
+		expanded = append(expanded, matches...)
+	}
+
+	return expanded, nil
+}
+
+/*
+Runs the command line given as args on the session, replacing every occurrence of placeholder in its arguments with value.
+*/
+func (s *Session) run(args []string, placeholder string, value string) error {
+	command, ok := GetCommands()[args[0]]
+	if !ok {
+		return ERUnknownCommand
+	}
+
+	replaced := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		replaced = append(replaced, strings.ReplaceAll(arg, placeholder, value))
+	}
+
+	return command.Callback(s, replaced...)
+}
+
+/*
+Runs step (Tree.Undo or Tree.Redo) as many times as the optional count in args says, printing each operation with verb. If the working directory
+is gone afterwards, the session goes back to the root.
+*/
+func (s *Session) replayJournal(args []string, step func() (string, error), verb string) error {
+	if len(args) > 1 {
+		return ERWrongParamCount
+	}
+
+	count := 1
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return ERInvalidNumber
+		}
+		count = n
+	}
+
+	defer s.leaveMissingCwd()
+	for range count {
+		op, err := step()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", verb, op)
+	}
+
+	return nil
+}
+
+/*
+Goes back to the root if the working directory is gone, e.g. after undoing the command that created it.
+*/
+func (s *Session) leaveMissingCwd() {
+	if node, err := s.tree.FollowPath(s.cwd); err != nil || !node.IsFolder() {
+		s.cwd = "/"
+	}
+}
+
+/*
+Returns the absolute path of a node as shown to the user.
+*/
+func (s *Session) nodePath(n tree.Node) string {
+	return path.Clean("/" + strings.TrimPrefix(s.tree.EvaluateNodePath(n), "./"))
+}
+
+/*
+Changes the working directory to p, which must be an existing folder.
+*/
+func (s *Session) chdir(p string) error {
+	abs := s.resolve(p)
+
+	node, err := s.tree.FollowPath(abs)
+	if err != nil {
+		return err
+	}
+
+	if node.IsFile() {
+		return tree.ETIExpectedFolderFoundFile
+	}
+
+	s.cwd = abs
+	return nil
+}
+
+/*
+Prints the current directory followed by the directory stack, most recent entry first (as the dirs builtin does).
+*/
+func (s *Session) printDirStack() {
+	fmt.Print(s.cwd)
+	for i := len(s.dirStack) - 1; i >= 0; i-- {
+		fmt.Print(" " + s.dirStack[i])
+	}
+	fmt.Println()
+}
+
+/*
+Replaces the content of the file that keeps src from being copied to dst with the content of src, as cp does. Anything but a file over a file
+is still reported as a duplicated name.
+*/
+func (s *Session) overwriteFile(src string, dst string) error {
+	content, err := s.tree.ReadFile(src)
+	if err != nil {
+		return tree.ETIDuplicatedName
+	}
+
+	target := dst
+	if dstNode, err := s.tree.FollowPath(dst); err == nil && dstNode.IsFolder() {
+		target = path.Join(dst, path.Base(src))
+	}
+
+	targetNode, err := s.tree.FollowPath(target)
+	if err != nil || !targetNode.IsFile() {
+		return tree.ETIDuplicatedName
+	}
+
+	return s.tree.WriteFile(target, content)
+}
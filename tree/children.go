@@ -0,0 +1,137 @@
+package tree
+
+import (
+	"iter"
+	"slices"
+)
+
+/*
+childList holds the children of a folder. They are indexed by their clean names, so looking up, adding, renaming and removing a child take
+constant time whatever the size of the folder, and linked in insertion order, which is the order folders are listed in. The zero value is an
+empty list.
+*/
+type childList struct {
+	byName map[string]*childEntry
+	first  *childEntry
+	last   *childEntry
+}
+
+type childEntry struct {
+	node Node
+	prev *childEntry
+	next *childEntry
+}
+
+/*
+Returns the amount of children.
+*/
+func (l *childList) len() int {
+	return len(l.byName)
+}
+
+/*
+Returns the child whose clean name is name, or nil if there is none.
+*/
+func (l *childList) get(name string) Node {
+	if e := l.byName[name]; e != nil {
+		return e.node
+	}
+
+	return nil
+}
+
+/*
+Returns true if n itself is one of the children, not just a node with the same name.
+*/
+func (l *childList) contains(n Node) bool {
+	e := l.byName[n.CleanName()]
+	return e != nil && e.node == n
+}
+
+/*
+Iterates over the children in order.
+*/
+func (l *childList) all() iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for e := l.first; e != nil; e = e.next {
+			if !yield(e.node) {
+				return
+			}
+		}
+	}
+}
+
+/*
+Returns the children in order, in a new slice.
+*/
+func (l *childList) slice() []Node {
+	return slices.AppendSeq(make([]Node, 0, l.len()), l.all())
+}
+
+/*
+Adds n right before the child next, or at the end if next is nil or is not a child. The name of n must not be taken, it is up to the caller to
+check it.
+*/
+func (l *childList) insert(n Node, next Node) {
+	if l.byName == nil {
+		l.byName = map[string]*childEntry{}
+	}
+
+	e := &childEntry{node: n}
+	l.byName[n.CleanName()] = e
+	if next == nil || !l.contains(next) {
+		e.prev = l.last
+		if l.last != nil {
+			l.last.next = e
+		} else {
+			l.first = e
+		}
+		l.last = e
+		return
+	}
+
+	at := l.byName[next.CleanName()]
+	e.prev, e.next = at.prev, at
+	if at.prev != nil {
+		at.prev.next = e
+	} else {
+		l.first = e
+	}
+	at.prev = e
+}
+
+/*
+Takes n out of the children, returning the child that followed it (nil if it was the last one). ok is false if n is not a child.
+*/
+func (l *childList) remove(n Node) (next Node, ok bool) {
+	if !l.contains(n) {
+		return nil, false
+	}
+
+	e := l.byName[n.CleanName()]
+	delete(l.byName, n.CleanName())
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		l.first = e.next
+	}
+
+	if e.next != nil {
+		e.next.prev = e.prev
+		next = e.next.node
+	} else {
+		l.last = e.prev
+	}
+
+	return next, true
+}
+
+/*
+Indexes the child named from under the name to, keeping its place. It must be called before the child itself is renamed.
+*/
+func (l *childList) rename(from string, to string) {
+	if e := l.byName[from]; e != nil {
+		delete(l.byName, from)
+		l.byName[to] = e
+	}
+}
@@ -0,0 +1,136 @@
+package tree
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+/*
+Returns the names of the children of the folder at path, in order.
+*/
+func childNames(t *testing.T, tree *Tree, path string) []string {
+	t.Helper()
+	children, err := tree.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, child := range children {
+		names = append(names, child.CleanName())
+	}
+	return names
+}
+
+func TestChildrenOrder(t *testing.T) {
+	tree := CreateTree()
+	for _, name := range []string{"c", "a", "b"} {
+		tree.CreateFile("/", name)
+	}
+	tree.CreateFolder("/", "d", false)
+
+	if got := childNames(t, tree, "/"); !slices.Equal(got, []string{"c", "a", "b", "d"}) {
+		t.Fatalf("children are not kept in insertion order: %q", got)
+	}
+
+	tree.Rename("/a", "z")
+	if got := childNames(t, tree, "/"); !slices.Equal(got, []string{"c", "z", "b", "d"}) {
+		t.Fatalf("renaming moved the child: %q", got)
+	}
+
+	if _, err := tree.FollowPath("/a"); err == nil {
+		t.Fatal("the old name still leads to the renamed child")
+	}
+
+	if _, err := tree.CreateFile("/", "z"); err == nil {
+		t.Fatal("the new name of the renamed child is not taken")
+	}
+
+	tree.RemoveFile("/z")
+	tree.RemoveFolder("/d", false)
+	tree.CreateFile("/", "a")
+	tree.Undo()
+	tree.Undo()
+	tree.Undo()
+	if got := childNames(t, tree, "/"); !slices.Equal(got, []string{"c", "z", "b", "d"}) {
+		t.Fatalf("undoing the removals did not put the children back in place: %q", got)
+	}
+
+	if node, err := tree.Root().SearchChild("d/"); err != nil || !node.IsFolder() {
+		t.Fatalf("searching the folder by its name failed: %v", err)
+	}
+
+	if _, err := tree.Root().SearchChild("z/"); err == nil {
+		t.Fatal("searching a file with the name of a folder should fail")
+	}
+}
+
+/*
+The amount of entries in the folders of the benchmarks.
+*/
+const wideFolderSize = 100_000
+
+/*
+Returns a tree whose /wide folder holds wideFolderSize files, named f0 to f99999.
+*/
+func wideTree(b *testing.B) *Tree {
+	b.Helper()
+	tree := CreateTree()
+	wide, err := tree.Root().InsertFolder("wide")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := range wideFolderSize {
+		if _, err := wide.InsertFile(fmt.Sprintf("f%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return tree
+}
+
+func BenchmarkFollowPathWideFolder(b *testing.B) {
+	tree := wideTree(b)
+	paths := []string{"/wide/f0", fmt.Sprintf("/wide/f%d", wideFolderSize/2), fmt.Sprintf("/wide/f%d", wideFolderSize-1)}
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := tree.FollowPath(paths[i%len(paths)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInsertFileWideFolder(b *testing.B) {
+	tree := wideTree(b)
+	wide := tree.Root().childNamed("wide").(*FolderNode)
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := wide.InsertFile(fmt.Sprintf("new%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRemoveNodeWideFolder(b *testing.B) {
+	tree := wideTree(b)
+	wide := tree.Root().childNamed("wide").(*FolderNode)
+	b.ResetTimer()
+	for i := range b.N {
+		name := fmt.Sprintf("f%d", i%wideFolderSize)
+		if err := wide.RemoveNode(name); err != nil {
+			b.Fatal(err)
+		}
+		wide.InsertFile(name)
+	}
+}
+
+func BenchmarkCreateFileWideFolder(b *testing.B) {
+	tree := wideTree(b)
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := tree.CreateFile("/wide", fmt.Sprintf("new%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
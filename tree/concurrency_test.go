@@ -0,0 +1,259 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+)
+
+/*
+Runs worker in n goroutines at once, giving each its number, and waits for all of them.
+*/
+func runConcurrently(n int, worker func(id int)) {
+	var wg sync.WaitGroup
+	for id := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker(id)
+		}()
+	}
+
+	wg.Wait()
+}
+
+/*
+Fails the test if the links between the nodes of tree or its inode table were left inconsistent.
+*/
+func checkConsistency(t *testing.T, tree *Tree) {
+	t.Helper()
+	inodes := map[*inode]bool{}
+	err := tree.Walk("/", false, func(nodePath string, n Node) error {
+		inodes[inodeOf(n)] = true
+		if folder, ok := n.(*FolderNode); ok {
+			for child := range folder.children.all() {
+				if child.Parent() != folder {
+					return fmt.Errorf("%s: wrong parent of %s", nodePath, child.Name())
+				}
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inodes) != tree.InodeCount() {
+		t.Fatalf("%d inodes are reachable, %d are in the table", len(inodes), tree.InodeCount())
+	}
+}
+
+func TestConcurrentOperations(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "shared", false)
+	const workers, rounds = 8, 30
+
+	runConcurrently(workers, func(id int) {
+		dir := fmt.Sprintf("/w%d", id)
+		tree.CreateFolder("/", fmt.Sprintf("w%d", id), false)
+		for i := range rounds {
+			file := fmt.Sprintf("%s/f%d", dir, i)
+			tree.CreateFile(dir, fmt.Sprintf("f%d", i))
+			tree.WriteFile(file, []byte(file))
+			tree.AppendFile(file, []byte("!"))
+			if data, err := tree.ReadFile(file); err != nil || string(data) != file+"!" {
+				t.Errorf("%s: unexpected content %q (%v)", file, data, err)
+			}
+
+			tree.CreateFolder(fmt.Sprintf("/shared/tmp%d-%d", id, i), "sub", true)
+			tree.Copy(file, fmt.Sprintf("/shared/tmp%d-%d/sub/copy", id, i), false)
+			tree.Rename(fmt.Sprintf("/shared/tmp%d-%d", id, i), fmt.Sprintf("gone%d-%d", id, i))
+			if err := tree.RemoveFolder(fmt.Sprintf("/shared/gone%d-%d", id, i), true); err != nil {
+				t.Errorf("worker %d: %v", id, err)
+			}
+
+			tree.FollowPath(file)
+			tree.ReadFile(fmt.Sprintf("/w%d/f%d", (id+1)%workers, i))
+			tree.SearchAll("copy")
+			tree.Glob("/w*/f1*")
+			tree.Find("/", FindOptions{MaxDepth: -1, Match: NameIs("copy")})
+			tree.Stat(dir)
+			tree.ReadDir("/shared")
+			tree.Hash("/")
+		}
+	})
+
+	for id := range workers {
+		for i := range rounds {
+			file := fmt.Sprintf("/w%d/f%d", id, i)
+			if data, err := tree.ReadFile(file); err != nil || string(data) != file+"!" {
+				t.Fatalf("%s: unexpected content %q (%v)", file, data, err)
+			}
+		}
+	}
+
+	if children, _ := tree.ReadDir("/shared"); len(children) != 0 {
+		t.Fatalf("%d nodes were left in /shared", len(children))
+	}
+
+	checkConsistency(t, tree)
+}
+
+func TestConcurrentHistory(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "data", false)
+	w, _ := tree.Watch("/", true)
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range w.Events {
+			n++
+		}
+		received <- n
+	}()
+
+	runConcurrently(6, func(id int) {
+		for i := range 40 {
+			switch id % 3 {
+			case 0:
+				tree.WriteFile(fmt.Sprintf("/data/%d-%d", id, i%5), []byte("data"))
+				tree.Undo()
+				tree.Redo()
+			case 1:
+				tree.Snapshot(fmt.Sprintf("snap%d", i%3))
+				if old, err := tree.SnapshotTree("snap0"); err == nil {
+					tree.Diff(old)
+					old.WriteFile("/data/private", []byte("private"))
+				}
+				tree.History()
+			default:
+				tree.Repository().Commit(fmt.Sprintf("commit %d-%d", id, i))
+				tree.Repository().Log("HEAD")
+				tree.Graft("/data", tree)
+			}
+		}
+	})
+
+	w.Close()
+	if <-received == 0 {
+		t.Fatal("the watcher received no events")
+	}
+
+	if _, err := tree.FollowPath("/data/private"); err == nil {
+		t.Fatal("writing to a snapshot copy changed the tree")
+	}
+
+	checkConsistency(t, tree)
+	if err := tree.Restore("snap0"); err != nil {
+		t.Fatal(err)
+	}
+
+	checkConsistency(t, tree)
+}
+
+func TestConcurrentHandles(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/log", nil)
+	fsys := tree.FS()
+	const writers, lines = 4, 500
+
+	runConcurrently(writers*2, func(id int) {
+		if id >= writers {
+			for range lines {
+				fs.WalkDir(fsys, ".", func(string, fs.DirEntry, error) error { return nil })
+				if f, err := fsys.Open("log"); err == nil {
+					io.ReadAll(f.(io.Reader))
+					f.Close()
+				}
+			}
+			return
+		}
+
+		f, err := tree.OpenFile("/log", os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+
+		for range lines {
+			fmt.Fprintf(f, "%d\n", id)
+		}
+	})
+
+	data, err := tree.ReadFile("/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id := range writers {
+		if n := bytes.Count(data, fmt.Appendf(nil, "%d\n", id)); n != lines {
+			t.Fatalf("writer %d: %d lines out of %d were kept", id, n, lines)
+		}
+	}
+}
+
+func TestConcurrentTransaction(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "data", false)
+	w, _ := tree.Watch("/", true)
+	defer w.Close()
+	const files = 100
+
+	tx, _ := tree.Begin()
+	runConcurrently(4, func(id int) {
+		for i := range files {
+			file := fmt.Sprintf("/data/f%d", i)
+			if id == 0 {
+				tx.Tree().WriteFile(file, []byte(file))
+				tx.Tree().AppendFile(file, []byte("!"))
+				continue
+			}
+
+			// the staged operations are not seen by the readers of the tree
+			if _, err := tree.ReadFile(file); err == nil {
+				t.Errorf("%s: the file of the open transaction is visible", file)
+			}
+			tree.ReadDir("/data")
+			tree.Hash("/")
+		}
+	})
+
+	if events := drainEvents(w); len(events) != 0 {
+		t.Fatalf("the open transaction was reported: %q", events)
+	}
+
+	runConcurrently(4, func(id int) {
+		if id == 0 {
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+
+		// readers see either none or all of the transaction
+		for range files {
+			if children, _ := tree.ReadDir("/data"); len(children) != 0 && len(children) != files {
+				t.Errorf("%d files of the transaction are visible, want 0 or %d", len(children), files)
+			}
+		}
+	})
+
+	for i := range files {
+		file := fmt.Sprintf("/data/f%d", i)
+		if data, err := tree.ReadFile(file); err != nil || string(data) != file+"!" {
+			t.Fatalf("%s: unexpected content %q (%v)", file, data, err)
+		}
+	}
+
+	if events := drainEvents(w); len(events) != 2*files { // a creation and a write for each file
+		t.Fatalf("%d events were sent for the transaction, want %d", len(events), 2*files)
+	}
+
+	checkConsistency(t, tree)
+}
@@ -0,0 +1,242 @@
+package tree
+
+import (
+	"bytes"
+	"cmp"
+	"maps"
+	"path"
+	"slices"
+)
+
+/*
+DiffKind tells how a node differs between two trees (see Diff).
+*/
+type DiffKind int
+
+const (
+	DiffAdded DiffKind = iota
+	DiffRemoved
+	DiffMoved
+	DiffModified
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffMoved:
+		return "moved"
+	case DiffModified:
+		return "modified"
+	}
+
+	return "unknown"
+}
+
+/*
+Implements encoding.TextMarshaler, so kinds are written by name in JSON.
+*/
+func (k DiffKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+/*
+Difference is a node that differs between two trees. Path is where the node is in the newer tree, or where it was in the older one if it was
+removed, and OldPath is where a moved node was. Fields lists what changed in the node itself, if anything: "content", "target", "mode",
+"owner", "group" and "attrs". Moved nodes may have changed as well.
+*/
+type Difference struct {
+	Kind    DiffKind `json:"kind"`
+	Path    string   `json:"path"`
+	OldPath string   `json:"old_path,omitempty"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+/*
+A node found while listing a tree for Diff, along with where it is.
+*/
+type diffEntry struct {
+	path   string
+	node   Node
+	parent uint64 // the inode number of the parent, 0 for the root
+}
+
+/*
+Returns the differences between t and newer, sorted by path. Nodes are matched by inode number, so the trees must come from the same one, as
+copies and snapshots do (see Clone and Snapshot): two unrelated trees have nothing in common. A number given to a different kind of node in each
+tree is a node removed and another one added.
+
+Nodes only in newer are added and nodes only in t are removed, every node inside a folder being reported along with it. A node is moved if its
+name or folder changed, while the nodes inside a moved folder are not reported, unless they changed themselves. Timestamps are not compared,
+nor are the children of folders, which are reported on their own.
+
+A folder with the same Hash in both trees holds the same subtree, so what is inside it is not compared at all (see unchangedFolders): hashing
+a tree that barely changed is cheap, and so is comparing it.
+*/
+func (t *Tree) Diff(newer *Tree) []Difference {
+	// newer is copied before locking t, as it may be t itself, and hashed first so the copy and later calls keep the hashes (see keepHashes)
+	newer.mu.Lock()
+	nodeHash(newer.Root())
+	copied := newer.clone()
+	newer.mu.Unlock()
+
+	t.mu.Lock() // the hashes are kept by the inodes, see Hash
+	defer t.mu.Unlock()
+	return diffTrees(t, copied)
+}
+
+func diffTrees(older *Tree, newer *Tree) []Difference {
+	unchanged := unchangedFolders(older, newer)
+	olds, news := diffEntries(older, unchanged), diffEntries(newer, unchanged)
+	diffs := []Difference{}
+	for ino, before := range olds {
+		after := news[ino]
+		if len(after) > 0 && before[0].node.Stat().Mode().Type() != after[0].node.Stat().Mode().Type() {
+			after = nil
+		}
+
+		pairs, removed, added := pairEntries(before, after)
+		for _, e := range removed {
+			diffs = append(diffs, Difference{Kind: DiffRemoved, Path: e.path})
+		}
+
+		for _, e := range added {
+			diffs = append(diffs, Difference{Kind: DiffAdded, Path: e.path})
+		}
+
+		for _, pair := range pairs {
+			fields := changedFields(pair[0].node, pair[1].node)
+			switch {
+			case pair[0].path != pair[1].path && (pair[0].parent != pair[1].parent || pair[0].node.CleanName() != pair[1].node.CleanName()):
+				diffs = append(diffs, Difference{Kind: DiffMoved, Path: pair[1].path, OldPath: pair[0].path, Fields: fields})
+			case len(fields) > 0:
+				diffs = append(diffs, Difference{Kind: DiffModified, Path: pair[1].path, Fields: fields})
+			}
+		}
+	}
+
+	for ino, after := range news {
+		before := olds[ino]
+		if len(before) > 0 && before[0].node.Stat().Mode().Type() == after[0].node.Stat().Mode().Type() {
+			continue
+		}
+
+		for _, e := range after {
+			diffs = append(diffs, Difference{Kind: DiffAdded, Path: e.path})
+		}
+	}
+
+	slices.SortFunc(diffs, func(a, b Difference) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.Kind, b.Kind))
+	})
+	return diffs
+}
+
+/*
+Returns a function telling whether the folder inode in, of older or newer, has the same hash in both trees. Such a folder is the same in both,
+wherever it is, and so is everything inside it, which Diff can skip. A node replaced by an identical one inside it is not told apart from the
+original, as the hashes leave inode numbers out.
+*/
+func unchangedFolders(older *Tree, newer *Tree) func(in *inode) bool {
+	nodeHash(older.Root()) // every folder hash is computed, or still valid
+	nodeHash(newer.Root())
+	return func(in *inode) bool {
+		before, after := older.inodes[in.ino], newer.inodes[in.ino]
+		return before != nil && after != nil && before.hashed && after.hashed && before.hash == after.hash
+	}
+}
+
+/*
+Lists every node of t (the root included) by inode number, leaving out what is inside the folders skip returns true for. Hard linked files have
+several entries, sorted by path.
+*/
+func diffEntries(t *Tree, skip func(in *inode) bool) map[uint64][]diffEntry {
+	entries := map[uint64][]diffEntry{}
+	var list func(nodePath string, n Node, parent uint64)
+	list = func(nodePath string, n Node, parent uint64) {
+		in := inodeOf(n)
+		entries[in.ino] = append(entries[in.ino], diffEntry{path: nodePath, node: n, parent: parent})
+		if folder, ok := n.(*FolderNode); ok && !skip(in) {
+			for child := range folder.children.all() {
+				list(path.Join(nodePath, child.CleanName()), child, in.ino)
+			}
+		}
+	}
+
+	list("/", t.Root(), 0)
+	for _, list := range entries {
+		slices.SortFunc(list, func(a, b diffEntry) int { return cmp.Compare(a.path, b.path) })
+	}
+
+	return entries
+}
+
+/*
+Matches the entries of the same inode in both trees: first the ones with the same folder and name, then the ones with the same path, then the
+rest in order. The entries left are the removed and the added links.
+*/
+func pairEntries(before []diffEntry, after []diffEntry) (pairs [][2]diffEntry, removed []diffEntry, added []diffEntry) {
+	after = slices.Clone(after)
+	matchers := []func(a, b diffEntry) bool{
+		func(a, b diffEntry) bool { return a.parent == b.parent && a.node.CleanName() == b.node.CleanName() },
+		func(a, b diffEntry) bool { return a.path == b.path },
+		func(a, b diffEntry) bool { return true },
+	}
+
+	for _, matches := range matchers {
+		left := []diffEntry{}
+		for _, a := range before {
+			index := slices.IndexFunc(after, func(b diffEntry) bool { return matches(a, b) })
+			if index < 0 {
+				left = append(left, a)
+				continue
+			}
+
+			pairs = append(pairs, [2]diffEntry{a, after[index]})
+			after = slices.Delete(after, index, index+1)
+		}
+		before = left
+	}
+
+	return pairs, before, after
+}
+
+/*
+Returns what differs between a and b, two versions of the same node (see Difference).
+*/
+func changedFields(a Node, b Node) []string {
+	fields := []string{}
+	if !bytes.Equal(inodeOf(a).content, inodeOf(b).content) {
+		fields = append(fields, "content")
+	}
+
+	if linkA, ok := a.(*SymlinkNode); ok {
+		if linkB, ok := b.(*SymlinkNode); ok && linkA.target != linkB.target {
+			fields = append(fields, "target")
+		}
+	}
+
+	metaA, metaB := metaOf(a), metaOf(b)
+	if metaA.mode != metaB.mode {
+		fields = append(fields, "mode")
+	}
+
+	if metaA.owner != metaB.owner {
+		fields = append(fields, "owner")
+	}
+
+	if metaA.group != metaB.group {
+		fields = append(fields, "group")
+	}
+
+	if !maps.Equal(metaA.attrs, metaB.attrs) {
+		fields = append(fields, "attrs")
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,659 @@
+package tree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+/*
+This file implements a reader for the subset of the Graphviz DOT language needed to rebuild a tree from a diagram: (di)graph declarations, node and
+edge statements (including chains such as a -> b -> c), attribute lists, default node attributes, graph attributes and subgraphs, whose statements are
+flattened into the main graph. Quoted, numeral and HTML IDs are supported, as well as C, C++ and shell style comments.
+
+The graph is turned into a tree by the following rules:
+- The node whose ID or label is "/" is the root. If there's none, every node without incoming edges becomes a child of the root.
+- The name of a node is its label attribute if present, otherwise its ID.
+- Nodes with shape=folder are folders and nodes with shape=note are files. Nodes without one of these shapes are folders if they have outgoing edges
+and files otherwise.
+- Nodes with shape=cds and a tooltip are symbolic links to the path in the tooltip, as written by Tree.GraphViz.
+- A node reached from more than one parent (as happened with the old graphviz output) is created under every one of them.
+*/
+
+type dotTokenKind int
+
+const (
+	dotEOF dotTokenKind = iota
+	dotID
+	dotPunct
+	dotEdgeOp
+)
+
+type dotToken struct {
+	kind  dotTokenKind
+	text  string
+	line  int
+	plain bool // true for unquoted IDs, the only ones that can be keywords
+}
+
+/*
+Splits a DOT document into tokens.
+*/
+type dotLexer struct {
+	reader    *bufio.Reader
+	line      int
+	lineStart bool // true while only blanks were read since the last line break
+}
+
+func (l *dotLexer) peekRune() (rune, bool) {
+	r, _, err := l.reader.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+
+	l.reader.UnreadRune()
+	return r, true
+}
+
+func (l *dotLexer) readRune() (rune, bool) {
+	r, _, err := l.reader.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+
+	if r == '\n' {
+		l.line++
+		l.lineStart = true
+	} else if !unicode.IsSpace(r) {
+		l.lineStart = false
+	}
+	return r, true
+}
+
+func (l *dotLexer) syntaxError(format string, args ...any) error {
+	return fmt.Errorf("%w (line %d: %s)", ETIDOTSyntax, l.line, fmt.Sprintf(format, args...))
+}
+
+/*
+Skips blanks and comments. Lines starting with # are preprocessor output in DOT and are ignored as comments as well.
+*/
+func (l *dotLexer) skipIgnored() error {
+	for {
+		r, ok := l.peekRune()
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			l.readRune()
+		case r == '#' && l.lineStart:
+			for r, ok := l.readRune(); ok && r != '\n'; r, ok = l.readRune() {
+			}
+		case r == '/':
+			l.readRune()
+			next, _ := l.peekRune()
+			switch next {
+			case '/':
+				for r, ok := l.readRune(); ok && r != '\n'; r, ok = l.readRune() {
+				}
+			case '*':
+				l.readRune()
+				closed := false
+				for prev, ok := rune(0), true; ok; {
+					var r rune
+					r, ok = l.readRune()
+					if prev == '*' && r == '/' {
+						closed = true
+						break
+					}
+					prev = r
+				}
+
+				if !closed {
+					return l.syntaxError("unterminated comment")
+				}
+			default:
+				return l.syntaxError("unexpected '/'")
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func isDOTIDRune(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) || r > unicode.MaxASCII
+}
+
+func (l *dotLexer) next() (dotToken, error) {
+	if err := l.skipIgnored(); err != nil {
+		return dotToken{}, err
+	}
+
+	line := l.line
+	r, ok := l.readRune()
+	if !ok {
+		return dotToken{kind: dotEOF, line: line}, nil
+	}
+
+	switch {
+	case strings.ContainsRune("{}[];,=:", r):
+		return dotToken{kind: dotPunct, text: string(r), line: line}, nil
+	case r == '-':
+		next, _ := l.peekRune()
+		if next == '>' || next == '-' {
+			l.readRune()
+			return dotToken{kind: dotEdgeOp, text: "-" + string(next), line: line}, nil
+		}
+		return l.readPlainID(r, line)
+	case r == '"':
+		return l.readQuotedID(line)
+	case r == '<':
+		return l.readHTMLID(line)
+	case isDOTIDRune(r):
+		return l.readPlainID(r, line)
+	}
+
+	return dotToken{}, l.syntaxError("unexpected character %q", r)
+}
+
+func (l *dotLexer) readPlainID(first rune, line int) (dotToken, error) {
+	var sb strings.Builder
+	sb.WriteRune(first)
+	for {
+		r, ok := l.peekRune()
+		if !ok || !isDOTIDRune(r) {
+			break
+		}
+		l.readRune()
+		sb.WriteRune(r)
+	}
+
+	return dotToken{kind: dotID, text: sb.String(), line: line, plain: true}, nil
+}
+
+/*
+Reads a double quoted ID. The only escape sequence is \" (as in the DOT specification), other backslashes are kept, except for line continuations.
+*/
+func (l *dotLexer) readQuotedID(line int) (dotToken, error) {
+	var sb strings.Builder
+	for {
+		r, ok := l.readRune()
+		if !ok {
+			return dotToken{}, l.syntaxError("unterminated string")
+		}
+
+		switch r {
+		case '"':
+			return dotToken{kind: dotID, text: sb.String(), line: line}, nil
+		case '\\':
+			next, ok := l.readRune()
+			if !ok {
+				return dotToken{}, l.syntaxError("unterminated string")
+			}
+
+			switch next {
+			case '"':
+				sb.WriteRune('"')
+			case '\n':
+			default:
+				sb.WriteRune('\\')
+				sb.WriteRune(next)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func (l *dotLexer) readHTMLID(line int) (dotToken, error) {
+	var sb strings.Builder
+	depth := 1
+	for {
+		r, ok := l.readRune()
+		if !ok {
+			return dotToken{}, l.syntaxError("unterminated HTML string")
+		}
+
+		if r == '<' {
+			depth++
+		} else if r == '>' {
+			depth--
+			if depth == 0 {
+				return dotToken{kind: dotID, text: sb.String(), line: line}, nil
+			}
+		}
+		sb.WriteRune(r)
+	}
+}
+
+/*
+The graph as read from the document: its nodes (in declaration order) with their attributes and its edges.
+*/
+type dotGraph struct {
+	order []string
+	attrs map[string]map[string]string
+	edges [][2]string
+}
+
+/*
+Declares the node id, or updates its attributes if it was already declared. The defaults only apply to nodes declared for the first time.
+*/
+func (g *dotGraph) declare(id string, defaults map[string]string, attrs map[string]string) {
+	current, ok := g.attrs[id]
+	if !ok {
+		current = map[string]string{}
+		for k, v := range defaults {
+			current[k] = v
+		}
+
+		g.attrs[id] = current
+		g.order = append(g.order, id)
+	}
+
+	for k, v := range attrs {
+		current[k] = v
+	}
+}
+
+type dotParser struct {
+	lexer *dotLexer
+	token dotToken
+	graph *dotGraph
+}
+
+func (p *dotParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.token = token
+	return nil
+}
+
+func (p *dotParser) isPunct(text string) bool {
+	return p.token.kind == dotPunct && p.token.text == text
+}
+
+func (p *dotParser) isKeyword(keyword string) bool {
+	return p.token.kind == dotID && p.token.plain && strings.EqualFold(p.token.text, keyword)
+}
+
+func (p *dotParser) expectPunct(text string) error {
+	if !p.isPunct(text) {
+		return p.unexpected("'" + text + "'")
+	}
+
+	return p.advance()
+}
+
+func (p *dotParser) unexpected(expected string) error {
+	found := p.token.text
+	if p.token.kind == dotEOF {
+		found = "end of file"
+	}
+
+	return fmt.Errorf("%w (line %d: expected %s, found %q)", ETIDOTSyntax, p.token.line, expected, found)
+}
+
+func (p *dotParser) expectID() (string, error) {
+	if p.token.kind != dotID {
+		return "", p.unexpected("an ID")
+	}
+
+	id := p.token.text
+	return id, p.advance()
+}
+
+/*
+graph : [ strict ] (graph | digraph) [ ID ] '{' stmt_list '}'
+*/
+func (p *dotParser) parseGraph() error {
+	if p.isKeyword("strict") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	if !p.isKeyword("graph") && !p.isKeyword("digraph") {
+		return p.unexpected("graph or digraph")
+	}
+
+	if err := p.advance(); err != nil {
+		return err
+	}
+
+	if p.token.kind == dotID {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	if err := p.parseBlock(map[string]string{}); err != nil {
+		return err
+	}
+
+	if p.token.kind != dotEOF {
+		return p.unexpected("end of file")
+	}
+
+	return nil
+}
+
+/*
+'{' stmt_list '}', where defaults are the node attributes set by enclosing blocks.
+*/
+func (p *dotParser) parseBlock(defaults map[string]string) error {
+	if err := p.expectPunct("{"); err != nil {
+		return err
+	}
+
+	scope := map[string]string{}
+	for k, v := range defaults {
+		scope[k] = v
+	}
+
+	for !p.isPunct("}") {
+		if p.token.kind == dotEOF {
+			return p.unexpected("'}'")
+		}
+
+		if err := p.parseStatement(scope); err != nil {
+			return err
+		}
+
+		if p.isPunct(";") {
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.advance()
+}
+
+func (p *dotParser) parseStatement(scope map[string]string) error {
+	switch {
+	case p.isKeyword("graph") || p.isKeyword("edge"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		_, err := p.parseAttrLists()
+		return err
+	case p.isKeyword("node"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		attrs, err := p.parseAttrLists()
+		for k, v := range attrs {
+			scope[k] = v
+		}
+		return err
+	case p.isKeyword("subgraph"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		if p.token.kind == dotID {
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+		return p.parseBlock(scope)
+	case p.isPunct("{"):
+		return p.parseBlock(scope)
+	}
+
+	id, err := p.parseNodeID()
+	if err != nil {
+		return err
+	}
+
+	if p.isPunct("=") { // graph attribute
+		if err := p.advance(); err != nil {
+			return err
+		}
+		_, err := p.expectID()
+		return err
+	}
+
+	chain := []string{id}
+	for p.token.kind == dotEdgeOp {
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		to, err := p.parseNodeID()
+		if err != nil {
+			return err
+		}
+		chain = append(chain, to)
+	}
+
+	attrs, err := p.parseAttrLists()
+	if err != nil {
+		return err
+	}
+
+	if len(chain) == 1 {
+		p.graph.declare(id, scope, attrs)
+		return nil
+	}
+
+	for i, node := range chain {
+		p.graph.declare(node, scope, nil)
+		if i > 0 {
+			p.graph.edges = append(p.graph.edges, [2]string{chain[i-1], node})
+		}
+	}
+
+	return nil
+}
+
+/*
+node_id : ID [ ':' ID [ ':' ID ] ]. Ports are read and discarded.
+*/
+func (p *dotParser) parseNodeID() (string, error) {
+	id, err := p.expectID()
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < 2 && p.isPunct(":"); i++ {
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+
+		if _, err := p.expectID(); err != nil {
+			return "", err
+		}
+	}
+
+	return id, nil
+}
+
+/*
+attr_list : '[' [ a_list ] ']' [ attr_list ]
+*/
+func (p *dotParser) parseAttrLists() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.isPunct("[") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		for !p.isPunct("]") {
+			key, err := p.expectID()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expectPunct("="); err != nil {
+				return nil, err
+			}
+
+			value, err := p.expectID()
+			if err != nil {
+				return nil, err
+			}
+			attrs[key] = value
+
+			if p.isPunct(",") || p.isPunct(";") {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	return attrs, nil
+}
+
+/*
+Reads a DOT document into a dotGraph.
+*/
+func parseDOTGraph(r io.Reader) (*dotGraph, error) {
+	p := &dotParser{
+		lexer: &dotLexer{reader: bufio.NewReader(r), line: 1, lineStart: true},
+		graph: &dotGraph{attrs: map[string]map[string]string{}},
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if err := p.parseGraph(); err != nil {
+		return nil, err
+	}
+
+	return p.graph, nil
+}
+
+/*
+Returns the name a graph node has in the tree.
+*/
+func (g *dotGraph) nodeName(id string) string {
+	if label, ok := g.attrs[id]["label"]; ok {
+		return label
+	}
+
+	return id
+}
+
+/*
+Rebuilds the graph nodes in ids (and their descendants) inside folder. The visiting set holds the folders in the current branch, used to detect cycles.
+*/
+func (g *dotGraph) build(ids []string, folder *FolderNode, children map[string][]string, visiting map[string]bool) error {
+	for _, id := range ids {
+		if visiting[id] {
+			return fmt.Errorf("%w (the graph has a cycle through %q)", ETIDOTNotATree, id)
+		}
+
+		isFolder := len(children[id]) > 0
+		switch g.attrs[id]["shape"] {
+		case "folder":
+			isFolder = true
+		case "note":
+			isFolder = false
+		}
+
+		name := g.nodeName(id)
+		if target, ok := g.attrs[id]["tooltip"]; ok && g.attrs[id]["shape"] == graphVizSymlinkShape {
+			if len(children[id]) > 0 {
+				return fmt.Errorf("%w (link %q has children)", ETIDOTNotATree, name)
+			}
+
+			if _, err := folder.InsertSymlink(name, target); err != nil {
+				return fmt.Errorf("%w (%q: %s)", ETIDOTNotATree, name, err)
+			}
+			continue
+		}
+
+		if !isFolder {
+			if len(children[id]) > 0 {
+				return fmt.Errorf("%w (file %q has children)", ETIDOTNotATree, name)
+			}
+
+			if _, err := folder.InsertFile(name); err != nil {
+				return fmt.Errorf("%w (%q: %s)", ETIDOTNotATree, name, err)
+			}
+			continue
+		}
+
+		created, err := folder.InsertFolder(name)
+		if err != nil {
+			return fmt.Errorf("%w (%q: %s)", ETIDOTNotATree, name, err)
+		}
+
+		visiting[id] = true
+		err = g.build(children[id], created, children, visiting)
+		delete(visiting, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Reads a Graphviz DOT document (such as the ones written by the graphviz command) and rebuilds the tree it describes. See the top of this file for
+the rules used to map the graph into a tree.
+*/
+func ParseDOT(r io.Reader) (*Tree, error) {
+	g, err := parseDOTGraph(r)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[string][]string{}
+	hasParent := map[string]bool{}
+	seenEdge := map[[2]string]bool{}
+	for _, edge := range g.edges {
+		if seenEdge[edge] {
+			continue
+		}
+
+		seenEdge[edge] = true
+		children[edge[0]] = append(children[edge[0]], edge[1])
+		hasParent[edge[1]] = true
+	}
+
+	var topLevel []string
+	visiting := map[string]bool{}
+	for _, id := range g.order {
+		if id == "/" || g.nodeName(id) == "/" {
+			if hasParent[id] {
+				return nil, fmt.Errorf("%w (the root has a parent)", ETIDOTNotATree)
+			}
+
+			topLevel = children[id]
+			visiting[id] = true
+			break
+		}
+	}
+
+	if len(visiting) == 0 {
+		for _, id := range g.order {
+			if !hasParent[id] {
+				topLevel = append(topLevel, id)
+			}
+		}
+	}
+
+	t := CreateTree()
+	if err := g.build(topLevel, t.Root(), children, visiting); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
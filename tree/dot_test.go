@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseDOTLegacyOutput(t *testing.T) {
+	doc := `digraph G {
+"/" -> "bin"
+"bin" -> "apps"
+"/" -> "home"
+"home" -> "user"
+"user" -> "desktop"
+"desktop" -> "image.png"
+"/" -> "lib"
+"lib" -> "image.png"
+}`
+
+	tree, err := ParseDOT(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"/bin/apps", "/home/user/desktop/image.png", "/lib/image.png"} {
+		node, err := tree.FollowPath(p)
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+
+		if !node.IsFile() {
+			t.Fatalf("%s: leaves should be files", p)
+		}
+	}
+}
+
+func TestParseDOTAttributes(t *testing.T) {
+	doc := `
+# generated
+strict digraph "my tree" {
+	// defaults apply to the nodes declared after them
+	rankdir=LR; node [shape=folder]
+	n0 [label="/"]
+	n1 [label="my \"docs\""]
+	n2 [label=readme, shape=note]
+	/* an empty folder */
+	n3 [label=empty]
+	n0 -> n1 -> n2
+	subgraph cluster_x { n0 -> n3 }
+}`
+
+	tree, err := ParseDOT(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node, err := tree.FollowPath(`/my "docs"/readme`); err != nil || !node.IsFile() {
+		t.Fatalf("readme should be a file (%v)", err)
+	}
+
+	if node, err := tree.FollowPath("/empty"); err != nil || !node.IsFolder() {
+		t.Fatalf("empty should be a folder (%v)", err)
+	}
+}
+
+func TestParseDOTErrors(t *testing.T) {
+	syntax := []string{`digraph {`, `graph { a -> }`, `digraph { a [label] }`, `digraph { "a }`, `tree { }`}
+	for _, doc := range syntax {
+		if _, err := ParseDOT(strings.NewReader(doc)); !errors.Is(err, ETIDOTSyntax) {
+			t.Fatalf("%s: expected a syntax error, got %v", doc, err)
+		}
+	}
+
+	invalid := []string{`digraph { "/" -> a -> b -> a }`, `digraph { a -> "/" }`, `digraph { b [label=a]; "/" -> a; "/" -> b }`}
+	for _, doc := range invalid {
+		if _, err := ParseDOT(strings.NewReader(doc)); !errors.Is(err, ETIDOTNotATree) {
+			t.Fatalf("%s: expected ETIDOTNotATree, got %v", doc, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Options for WriteToDir.
+*/
+type WriteOptions struct {
+	// Works out (and reports) what would be written without touching the host file system.
+	DryRun bool
+	// Replaces the content of host files that already exist. Without it, an existing file is an error.
+	Overwrite bool
+	// Fails if the host directory exists and is not empty.
+	RequireEmpty bool
+}
+
+/*
+What WriteToDir did (or would do, on a dry run) on the host file system. Every entry is a host path.
+*/
+type WriteReport struct {
+	Created     []string
+	Overwritten []string
+	// Folders that already existed and were reused.
+	Existing []string
+}
+
+type writeStep struct {
+	hostPath string
+	file     *FileNode    // nil for folders and links
+	link     *SymlinkNode // nil for folders and files
+	exists   bool
+}
+
+/*
+Works out the steps needed to write fn's descendants inside hostDir, failing if anything on the host is in the way.
+*/
+func (fn *FolderNode) planWrite(hostDir string, opts WriteOptions, steps []writeStep) ([]writeStep, error) {
+	for child := range fn.children.all() {
+		hostPath := filepath.Join(hostDir, child.CleanName())
+		info, err := os.Lstat(hostPath)
+		exists := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		switch node := child.(type) {
+		case *FolderNode:
+			if exists && !info.IsDir() {
+				return nil, fmt.Errorf("%s: %w", hostPath, ETIExpectedFolderFoundFile)
+			}
+
+			steps = append(steps, writeStep{hostPath: hostPath, exists: exists})
+			if steps, err = node.planWrite(hostPath, opts, steps); err != nil {
+				return nil, err
+			}
+		case *FileNode:
+			if exists && !info.Mode().IsRegular() {
+				return nil, fmt.Errorf("%s: %w", hostPath, ETIExpectedFileFoundFolder)
+			}
+
+			if exists && !opts.Overwrite {
+				return nil, fmt.Errorf("%s: %w", hostPath, fs.ErrExist)
+			}
+
+			steps = append(steps, writeStep{hostPath: hostPath, file: node, exists: exists})
+		case *SymlinkNode:
+			if exists && info.Mode()&fs.ModeSymlink == 0 {
+				return nil, fmt.Errorf("%s: %w", hostPath, ETINotASymlink)
+			}
+
+			if exists && !opts.Overwrite {
+				return nil, fmt.Errorf("%s: %w", hostPath, fs.ErrExist)
+			}
+
+			steps = append(steps, writeStep{hostPath: hostPath, link: node, exists: exists})
+		}
+	}
+
+	return steps, nil
+}
+
+/*
+Creates the folders and files of the tree on the host file system, inside hostDir (which is created if needed). Everything that could get in
+the way is checked before writing anything, so a conflict leaves the host untouched. Folders are created with mode 0755 and files with 0644.
+Symbolic links are recreated as host links, with absolute targets made relative so that they keep pointing inside hostDir.
+The name differs from the usual WriteTo since it doesn't follow io.WriterTo.
+*/
+func (t *Tree) WriteToDir(hostDir string, opts WriteOptions) (*WriteReport, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	report := &WriteReport{}
+
+	info, err := os.Stat(hostDir)
+	switch {
+	case err == nil && !info.IsDir():
+		return nil, fmt.Errorf("%s: %w", hostDir, ETIExpectedFolderFoundFile)
+	case err == nil && opts.RequireEmpty:
+		entries, err := os.ReadDir(hostDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(entries) > 0 {
+			return nil, fmt.Errorf("%s: %w", hostDir, ETIHostDirNotEmpty)
+		}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	if err != nil {
+		report.Created = append(report.Created, hostDir)
+	}
+
+	steps, err := t.Root().planWrite(hostDir, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range steps {
+		switch {
+		case step.file == nil && step.link == nil && step.exists:
+			report.Existing = append(report.Existing, step.hostPath)
+		case step.exists:
+			report.Overwritten = append(report.Overwritten, step.hostPath)
+		default:
+			report.Created = append(report.Created, step.hostPath)
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	if err := os.MkdirAll(hostDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	for _, step := range steps {
+		if step.link != nil {
+			if err := writeSymlink(hostDir, step); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		if step.file == nil {
+			if !step.exists {
+				if err := os.Mkdir(step.hostPath, 0o755); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+
+		if err := os.WriteFile(step.hostPath, step.file.content, 0o644); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+/*
+Creates the host link described by step, replacing the existing one if needed. hostDir is the directory the tree is written to.
+*/
+func writeSymlink(hostDir string, step writeStep) error {
+	target := step.link.Target()
+	if strings.HasPrefix(target, "/") {
+		rel, err := filepath.Rel(filepath.Dir(step.hostPath), filepath.Join(hostDir, filepath.FromSlash(target)))
+		if err != nil {
+			return err
+		}
+		target = rel
+	} else {
+		target = filepath.FromSlash(target)
+	}
+
+	if step.exists {
+		if err := os.Remove(step.hostPath); err != nil {
+			return err
+		}
+	}
+
+	return os.Symlink(target, step.hostPath)
+}
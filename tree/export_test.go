@@ -0,0 +1,70 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteToDir(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/a", "b", true)
+	tree.WriteFile("/a/b/f.txt", []byte("hello"))
+	tree.CreateFile("/", "g")
+
+	host := filepath.Join(t.TempDir(), "out")
+	report, err := tree.WriteToDir(host, WriteOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Created) != 5 {
+		t.Fatalf("unexpected dry run report %v", report.Created)
+	}
+
+	if _, err := os.Stat(host); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("a dry run should not touch the disk")
+	}
+
+	if _, err := tree.WriteToDir(host, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := FromFS(os.DirFS(host), ".", FromFSOptions{Content: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := imported.ReadFile("/a/b/f.txt"); err != nil || string(content) != "hello" {
+		t.Fatalf("unexpected content %q (%v)", content, err)
+	}
+
+	tree.WriteFile("/a/b/f.txt", []byte("changed"))
+	tree.CreateFile("/a", "new")
+	if _, err := tree.WriteToDir(host, WriteOptions{}); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(host, "a", "new")); err == nil {
+		t.Fatal("a conflict should leave the host untouched")
+	}
+
+	if _, err := tree.WriteToDir(host, WriteOptions{Overwrite: true, RequireEmpty: true}); !errors.Is(err, ETIHostDirNotEmpty) {
+		t.Fatalf("expected ETIHostDirNotEmpty, got %v", err)
+	}
+
+	report, err = tree.WriteToDir(host, WriteOptions{Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Created) != 1 || len(report.Overwritten) != 2 || len(report.Existing) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(host, "a", "b", "f.txt")); string(content) != "changed" {
+		t.Fatalf("file not overwritten: %q", content)
+	}
+}
@@ -0,0 +1,380 @@
+package tree
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+/*
+File is an open handle to a node of the tree, modeled on os.File so the tree can stand in for the real file system in code that writes files.
+Handles are obtained through Tree.OpenFile (or its shortcuts Tree.Open and Tree.Create) and keep working if the node is renamed or moved. As on
+Unix, the content of a file that is removed while open stays available to its handles until the last of them is closed.
+
+Reads and writes go straight to the node, so every handle sees the changes made by the others. Folders can be opened for reading only, in which
+case ReadDir lists their content. Errors are returned as *fs.PathError, as os does. Handles take the lock of their tree (see Tree), so they
+can be used while other goroutines work on the tree, but a single handle has one offset and is best kept to one goroutine.
+*/
+type File struct {
+	tree    *Tree
+	node    Node
+	name    string
+	flag    int
+	offset  int64
+	entries []fs.DirEntry // folder entries still to be returned by ReadDir
+	closed  bool
+}
+
+/*
+Opens the node at name. flag is a combination of the os package's O_* constants: O_RDONLY, O_WRONLY or O_RDWR plus any of O_CREATE (creates the
+file if it does not exist), O_EXCL (used with O_CREATE, fails if the file exists), O_APPEND (every write goes to the end of the file) and O_TRUNC
+(empties the file when it is opened for writing). A file created by the call gets the permission bits of perm without the umask ones.
+*/
+func (t *Tree) OpenFile(name string, flag int, perm fs.FileMode) (_ *File, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "open %s", name)()
+
+	node, err := t.follow(name)
+	created := false
+	switch {
+	case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		err = ETIDuplicatedName
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE != 0:
+		var file *FileNode
+		if file, err = t.createFileAt(name); err == nil {
+			file.meta.mode = perm.Perm() &^ t.umask
+			node, created = file, true
+		}
+	}
+
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	file, isFile := node.(*FileNode)
+	if !isFile && writable {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ETIExpectedFileFoundFolder}
+	}
+
+	// As with os.OpenFile, the mode of a file created by the call doesn't apply to the handle returned by it.
+	if !created {
+		var want fs.FileMode
+		if flag&os.O_WRONLY == 0 {
+			want |= accessRead
+		}
+		if writable {
+			want |= accessWrite
+		}
+
+		if err := t.checkAccess(node, want); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	if isFile && writable && flag&os.O_TRUNC != 0 {
+		file.Truncate(0)
+	}
+
+	return openNode(t, node, name, flag), nil
+}
+
+/*
+Opens the node at name for reading.
+*/
+func (t *Tree) Open(name string) (*File, error) {
+	return t.OpenFile(name, os.O_RDONLY, 0)
+}
+
+/*
+Creates (or empties, if it exists) the file at name and opens it for reading and writing.
+*/
+func (t *Tree) Create(name string) (*File, error) {
+	return t.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+/*
+Returns a handle to node, which has already been checked against flag.
+*/
+func openNode(t *Tree, node Node, name string, flag int) *File {
+	inodeOf(node).open++
+	return &File{tree: t, node: node, name: name, flag: flag}
+}
+
+func (f *File) pathError(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+/*
+Returns the file node behind the handle after checking that the handle can be used by op.
+*/
+func (f *File) check(op string, write bool) (*FileNode, error) {
+	if f.closed {
+		return nil, f.pathError(op, fs.ErrClosed)
+	}
+
+	file, err := f.node.AsFile()
+	if err != nil {
+		return nil, f.pathError(op, ETIExpectedFileFoundFolder)
+	}
+
+	access := f.flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	if write && access == os.O_RDONLY {
+		return nil, f.pathError(op, ETINotOpenForWriting)
+	}
+
+	if !write && access == os.O_WRONLY {
+		return nil, f.pathError(op, ETINotOpenForReading)
+	}
+
+	return file, nil
+}
+
+/*
+Returns the name the node was opened with.
+*/
+func (f *File) Name() string {
+	return f.name
+}
+
+/*
+Implements io.Reader.
+*/
+func (f *File) Read(p []byte) (int, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	file, err := f.check("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := file.readAt(p, f.offset)
+	f.offset += int64(n)
+	file.meta.accessed(file.now())
+	return n, err
+}
+
+/*
+Implements io.ReaderAt. The offset of the handle is not changed.
+*/
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	file, err := f.check("read", false)
+	if err != nil {
+		return 0, err
+	}
+
+	if off < 0 {
+		return 0, f.pathError("read", ETIInvalidOffset)
+	}
+
+	file.meta.accessed(file.now())
+	return file.readAt(p, off)
+}
+
+/*
+Implements io.Writer. Files opened with O_APPEND always write at their end.
+*/
+func (f *File) Write(p []byte) (int, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	file, err := f.check("write", true)
+	if err != nil {
+		return 0, err
+	}
+
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(file.Size())
+	}
+
+	if err := file.writeAt(p, f.offset); err != nil {
+		return 0, f.pathError("write", err)
+	}
+
+	f.offset += int64(len(p))
+	f.tree.notifyWrite(file)
+	return len(p), nil
+}
+
+/*
+Writes a string to the file, see Write.
+*/
+func (f *File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+/*
+Implements io.WriterAt. The offset of the handle is not changed and, as in os, it cannot be used on files opened with O_APPEND.
+*/
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	file, err := f.check("write", true)
+	if err != nil {
+		return 0, err
+	}
+
+	if f.flag&os.O_APPEND != 0 {
+		return 0, f.pathError("write", ETIWriteAtInAppendMode)
+	}
+
+	if off < 0 {
+		return 0, f.pathError("write", ETIInvalidOffset)
+	}
+
+	if err := file.writeAt(p, off); err != nil {
+		return 0, f.pathError("write", err)
+	}
+
+	f.tree.notifyWrite(file)
+	return len(p), nil
+}
+
+/*
+Implements io.Seeker. Seeking past the end is allowed, up to MaxFileSize, the gap is zeroed by the next write. Folders can only be rewound
+(Seek(0, io.SeekStart)).
+*/
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	if f.closed {
+		return 0, f.pathError("seek", fs.ErrClosed)
+	}
+
+	file, isFile := f.node.(*FileNode)
+	if !isFile {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, f.pathError("seek", ETIInvalidOffset)
+		}
+
+		f.entries = nil
+		return 0, nil
+	}
+
+	var base int64
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		base = f.offset
+	case io.SeekEnd:
+		base = int64(file.Size())
+	default:
+		return 0, f.pathError("seek", ETIInvalidOffset)
+	}
+
+	// base is never over the size of a file, so checking offset first keeps the sum from overflowing
+	if offset > MaxFileSize || offset < -MaxFileSize {
+		return 0, f.pathError("seek", ETIInvalidOffset)
+	}
+
+	offset += base
+	if offset < 0 || offset > MaxFileSize {
+		return 0, f.pathError("seek", ETIInvalidOffset)
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+/*
+Changes the size of the file, see FileNode.Truncate. The offset of the handle is not changed.
+*/
+func (f *File) Truncate(size int64) error {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	file, err := f.check("truncate", true)
+	if err != nil {
+		return err
+	}
+
+	if size > MaxFileSize {
+		return f.pathError("truncate", ETIFileTooLarge)
+	}
+
+	if err := file.Truncate(int(size)); err != nil {
+		return f.pathError("truncate", err)
+	}
+
+	f.tree.notifyWrite(file)
+	return nil
+}
+
+/*
+Returns the description of the node behind the handle.
+*/
+func (f *File) Stat() (fs.FileInfo, error) {
+	f.tree.mu.RLock()
+	defer f.tree.mu.RUnlock()
+
+	if f.closed {
+		return nil, f.pathError("stat", fs.ErrClosed)
+	}
+
+	return f.node.Stat().renamed(path.Base(f.name)), nil
+}
+
+/*
+Implements fs.ReadDirFile for handles to folders, following the same rules as os.File.ReadDir: with n > 0 at most n entries are returned and
+io.EOF signals the end, otherwise all the remaining entries are returned at once. Entries are sorted by name.
+*/
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	if f.closed {
+		return nil, f.pathError("readdir", fs.ErrClosed)
+	}
+
+	folder, err := f.node.AsFolder()
+	if err != nil {
+		return nil, f.pathError("readdir", ETIExpectedFolderFoundFile)
+	}
+
+	if f.entries == nil {
+		f.entries = dirEntries(folder)
+		folder.meta.accessed(folder.now())
+	}
+
+	if n <= 0 {
+		left := f.entries
+		f.entries = []fs.DirEntry{}
+		return left, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(f.entries))
+	left := f.entries[:n]
+	f.entries = f.entries[n:]
+	return left, nil
+}
+
+/*
+Closes the handle. Any later use of it fails with fs.ErrClosed.
+*/
+func (f *File) Close() error {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	if f.closed {
+		return f.pathError("close", fs.ErrClosed)
+	}
+
+	f.closed = true
+	in := inodeOf(f.node)
+	in.open--
+	in.release()
+	return nil
+}
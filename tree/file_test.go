@@ -0,0 +1,175 @@
+package tree
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestOpenFileFlags(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "dir", false)
+
+	if _, err := tree.OpenFile("/dir/a.txt", os.O_WRONLY, 0); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	f, err := tree.OpenFile("/dir/a.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("hello world")
+
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, ETINotOpenForReading) {
+		t.Fatalf("expected ETINotOpenForReading, got %v", err)
+	}
+	f.Close()
+
+	if _, err := f.Write([]byte("x")); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("expected fs.ErrClosed, got %v", err)
+	}
+
+	if _, err := tree.OpenFile("/dir/a.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist, got %v", err)
+	}
+
+	f, _ = tree.OpenFile("/dir/a.txt", os.O_RDWR|os.O_APPEND, 0)
+	f.Write([]byte("!"))
+	if _, err := f.WriteAt([]byte("x"), 0); !errors.Is(err, ETIWriteAtInAppendMode) {
+		t.Fatalf("expected ETIWriteAtInAppendMode, got %v", err)
+	}
+	f.Close()
+
+	if content, _ := tree.ReadFile("/dir/a.txt"); string(content) != "hello world!" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	f, _ = tree.OpenFile("/dir/a.txt", os.O_RDWR|os.O_TRUNC, 0)
+	if info, _ := f.Stat(); info.Size() != 0 {
+		t.Fatalf("O_TRUNC did not empty the file: %d", info.Size())
+	}
+	f.Close()
+
+	if _, err := tree.OpenFile("/dir", os.O_RDWR, 0); !errors.Is(err, ETIExpectedFileFoundFolder) {
+		t.Fatalf("expected ETIExpectedFileFoundFolder, got %v", err)
+	}
+
+	d, err := tree.Open("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Read(make([]byte, 1)); !errors.Is(err, ETIExpectedFileFoundFolder) {
+		t.Fatalf("expected ETIExpectedFileFoundFolder, got %v", err)
+	}
+
+	if entries, err := d.ReadDir(-1); err != nil || len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Fatalf("unexpected entries %v (%v)", entries, err)
+	}
+}
+
+func TestFileReadWriteSeek(t *testing.T) {
+	tree := CreateTree()
+	f, err := tree.Create("/data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Write([]byte("abcdef"))
+	if pos, _ := f.Seek(2, io.SeekStart); pos != 2 {
+		t.Fatalf("unexpected position %d", pos)
+	}
+
+	buf := make([]byte, 3)
+	if n, err := f.Read(buf); n != 3 || err != nil || string(buf) != "cde" {
+		t.Fatalf("unexpected read %q (%d, %v)", buf, n, err)
+	}
+
+	f.WriteAt([]byte("XY"), 8)
+	if content, _ := tree.ReadFile("/data.bin"); string(content) != "abcdef\x00\x00XY" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	if n, err := f.ReadAt(buf, 8); n != 2 || err != io.EOF {
+		t.Fatalf("expected a short read with io.EOF, got %d (%v)", n, err)
+	}
+
+	f.Truncate(3)
+	f.Seek(0, io.SeekStart)
+	all, err := io.ReadAll(f)
+	if err != nil || string(all) != "abc" {
+		t.Fatalf("unexpected content %q (%v)", all, err)
+	}
+
+	if _, err := f.Seek(-1, io.SeekStart); !errors.Is(err, ETIInvalidOffset) {
+		t.Fatalf("expected ETIInvalidOffset, got %v", err)
+	}
+
+	// handles follow the node when it is moved
+	tree.CreateFolder("/", "moved", false)
+	tree.Move("/data.bin", "/moved")
+	f.Seek(0, io.SeekEnd)
+	f.WriteString("d")
+	if content, _ := tree.ReadFile("/moved/data.bin"); string(content) != "abcd" {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestFileSizeLimits(t *testing.T) {
+	tree := CreateTree()
+	f, err := tree.Create("/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("data")
+	done, _ := tree.History()
+
+	for _, c := range []struct {
+		offset int64
+		whence int
+	}{
+		{math.MaxInt64 - 1, io.SeekStart},
+		{MaxFileSize + 1, io.SeekStart},
+		{math.MaxInt64, io.SeekEnd},
+		{math.MinInt64, io.SeekCurrent},
+	} {
+		if _, err := f.Seek(c.offset, c.whence); !errors.Is(err, ETIInvalidOffset) {
+			t.Fatalf("seeking to %d (%d): expected ETIInvalidOffset, got %v", c.offset, c.whence, err)
+		}
+	}
+
+	if pos, err := f.Seek(MaxFileSize-2, io.SeekStart); err != nil || pos != MaxFileSize-2 {
+		t.Fatalf("seeking up to the maximum size failed: %d (%v)", pos, err)
+	}
+
+	if _, err := f.Write([]byte("0123456789")); !errors.Is(err, ETIFileTooLarge) {
+		t.Fatalf("expected ETIFileTooLarge, got %v", err)
+	}
+
+	for _, off := range []int64{math.MaxInt64 - 1, MaxFileSize} {
+		if _, err := f.WriteAt([]byte("0123456789"), off); !errors.Is(err, ETIFileTooLarge) {
+			t.Fatalf("writing at %d: expected ETIFileTooLarge, got %v", off, err)
+		}
+	}
+
+	for _, size := range []int64{1 << 40, math.MaxInt64} {
+		if err := f.Truncate(size); !errors.Is(err, ETIFileTooLarge) {
+			t.Fatalf("truncating to %d: expected ETIFileTooLarge, got %v", size, err)
+		}
+	}
+
+	if err := tree.Truncate("/big", MaxFileSize+1); !errors.Is(err, ETIFileTooLarge) {
+		t.Fatalf("expected ETIFileTooLarge, got %v", err)
+	}
+
+	if content, _ := tree.ReadFile("/big"); string(content) != "data" {
+		t.Fatalf("a rejected operation changed the file: %q", content)
+	}
+
+	if now, _ := tree.History(); len(now) != len(done) {
+		t.Fatal("a rejected operation was recorded in the journal")
+	}
+}
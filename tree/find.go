@@ -0,0 +1,232 @@
+package tree
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/*
+Predicate tells whether the node n, reached through nodePath, is one of the results of a search (see Find). Predicates are built with the
+functions below and combined with And, Or and Not, the same way the tests of find(1) are.
+*/
+type Predicate func(nodePath string, n Node) bool
+
+/*
+FindOptions describes a search. Only the nodes between MinDepth and MaxDepth steps away from the start (which is at depth 0) are tested, and a
+negative MaxDepth means no limit. A nil Match matches every node.
+*/
+type FindOptions struct {
+	FollowLinks bool
+	MinDepth    int
+	MaxDepth    int
+	Match       Predicate
+}
+
+/*
+Found is a result of Find: a node and the path it was reached through.
+*/
+type Found struct {
+	Path string
+	Node Node
+}
+
+/*
+Returns every node under root (root included) matched by the search described by opts, in the order Walk visits them. As with Walk, opts.Match
+is called with the tree locked and must not use it.
+*/
+func (t *Tree) Find(root string, opts FindOptions) ([]Found, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.find(root, opts)
+}
+
+func (t *Tree) find(root string, opts FindOptions) ([]Found, error) {
+	start := len(splitPath(root))
+	results := []Found{}
+	err := t.walkPath(root, opts.FollowLinks, func(nodePath string, n Node) error {
+		depth := len(splitPath(nodePath)) - start
+		if depth >= opts.MinDepth && (opts.Match == nil || opts.Match(nodePath, n)) {
+			results = append(results, Found{Path: nodePath, Node: n})
+		}
+
+		if opts.MaxDepth >= 0 && depth >= opts.MaxDepth && n.IsFolder() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+/*
+Returns every node under the root (the root excluded) named str.
+*/
+func (t *Tree) SearchAll(str string) ([]Node, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	found, err := t.find("/", FindOptions{MinDepth: 1, MaxDepth: -1, Match: NameIs(str)})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Node, 0, len(found))
+	for _, f := range found {
+		res = append(res, f.Node)
+	}
+
+	return res, nil
+}
+
+/*
+Matches the nodes named exactly name.
+*/
+func NameIs(name string) Predicate {
+	return func(_ string, n Node) bool {
+		return n.CleanName() == name
+	}
+}
+
+/*
+Matches the nodes whose name matches the glob pattern, with the syntax of a single step of Glob (braces included). If fold is true the case of
+the letters is ignored, as in -iname.
+*/
+func NameMatches(pattern string, fold bool) (Predicate, error) {
+	if fold {
+		pattern = strings.ToLower(pattern)
+	}
+
+	alternatives := expandBraces(pattern)
+	for i, alternative := range alternatives {
+		alternatives[i] = shellClasses(alternative)
+		if _, err := path.Match(alternatives[i], ""); err != nil {
+			return nil, ETIBadPattern
+		}
+	}
+
+	return func(_ string, n Node) bool {
+		name := n.CleanName()
+		if fold {
+			name = strings.ToLower(name)
+		}
+
+		for _, alternative := range alternatives {
+			if matched, _ := path.Match(alternative, name); matched {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+/*
+Matches the nodes whose whole path (not only a part of it) matches re, as in -regex.
+*/
+func PathMatches(re *regexp.Regexp) Predicate {
+	return func(nodePath string, _ Node) bool {
+		loc := re.FindStringIndex(nodePath)
+		return loc != nil && loc[0] == 0 && loc[1] == len(nodePath)
+	}
+}
+
+/*
+Matches the nodes of a kind: 'f' for files, 'd' for folders and 'l' for links.
+*/
+func TypeIs(kind byte) Predicate {
+	return func(_ string, n Node) bool {
+		switch kind {
+		case 'f':
+			return n.IsFile()
+		case 'd':
+			return n.IsFolder()
+		case 'l':
+			return n.IsSymlink()
+		}
+		return false
+	}
+}
+
+/*
+Compares the size of the nodes, rounded up to a multiple of unit bytes, with count units: the nodes bigger than that match if cmp is positive,
+the smaller ones if it is negative and the ones of exactly that size if it is zero. This is how -size +N, -N and N behave.
+*/
+func SizeIs(cmp int, count int64, unit int64) Predicate {
+	return func(_ string, n Node) bool {
+		size := (n.Stat().Size() + unit - 1) / unit
+		switch {
+		case cmp > 0:
+			return size > count
+		case cmp < 0:
+			return size < count
+		}
+		return size == count
+	}
+}
+
+/*
+Matches the nodes modified after ref, as in -newer.
+*/
+func NewerThan(ref Node) Predicate {
+	modTime := ref.Stat().ModTime()
+	return func(_ string, n Node) bool {
+		return n.Stat().ModTime().After(modTime)
+	}
+}
+
+/*
+Matches the empty files and the folders without children.
+*/
+func IsEmpty() Predicate {
+	return func(_ string, n Node) bool {
+		switch node := n.(type) {
+		case *FileNode:
+			return node.Size() == 0
+		case *FolderNode:
+			return !node.HasChildren()
+		}
+		return false
+	}
+}
+
+/*
+Matches the nodes matched by every one of preds.
+*/
+func And(preds ...Predicate) Predicate {
+	return func(nodePath string, n Node) bool {
+		for _, p := range preds {
+			if !p(nodePath, n) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+/*
+Matches the nodes matched by any of preds.
+*/
+func Or(preds ...Predicate) Predicate {
+	return func(nodePath string, n Node) bool {
+		for _, p := range preds {
+			if p(nodePath, n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+/*
+Matches the nodes pred does not match.
+*/
+func Not(pred Predicate) Predicate {
+	return func(nodePath string, n Node) bool {
+		return !pred(nodePath, n)
+	}
+}
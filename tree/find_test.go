@@ -0,0 +1,70 @@
+package tree
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func foundPaths(found []Found) []string {
+	paths := []string{}
+	for _, f := range found {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func TestFindPredicates(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tree := CreateTreeWithClock(clock)
+	tree.CreateFolder("/src/pkg", "empty", true)
+	tree.WriteFile("/src/main.go", []byte("package main"))
+	clock.advance()
+	tree.WriteFile("/src/pkg/util.go", []byte("package pkg"))
+	tree.Touch("/src/README.md")
+	tree.Symlink("/src/main.go", "/src/link")
+
+	all := func(opts FindOptions) []string {
+		found, err := tree.Find("/src", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return foundPaths(found)
+	}
+
+	goFiles, _ := NameMatches("*.{go,md}", false)
+	readme, _ := NameMatches("readme*", true)
+	newer := NewerThan(mustFollow(t, tree, "/src/main.go"))
+
+	cases := []struct {
+		name string
+		opts FindOptions
+		want []string
+	}{
+		{"everything", FindOptions{MaxDepth: -1}, []string{"/src", "/src/pkg", "/src/pkg/empty", "/src/pkg/util.go", "/src/main.go", "/src/README.md", "/src/link"}},
+		{"depth", FindOptions{MinDepth: 1, MaxDepth: 1}, []string{"/src/pkg", "/src/main.go", "/src/README.md", "/src/link"}},
+		{"type", FindOptions{MaxDepth: -1, Match: TypeIs('l')}, []string{"/src/link"}},
+		{"name", FindOptions{MaxDepth: -1, Match: goFiles}, []string{"/src/pkg/util.go", "/src/main.go", "/src/README.md"}},
+		{"iname", FindOptions{MaxDepth: -1, Match: readme}, []string{"/src/README.md"}},
+		{"regex", FindOptions{MaxDepth: -1, Match: PathMatches(regexp.MustCompile(`/src/[a-z]+\.go`))}, []string{"/src/main.go"}},
+		{"size", FindOptions{MaxDepth: -1, Match: And(TypeIs('f'), SizeIs(1, 11, 1))}, []string{"/src/main.go"}},
+		{"empty", FindOptions{MaxDepth: -1, Match: IsEmpty()}, []string{"/src/pkg/empty", "/src/README.md"}},
+		{"newer", FindOptions{MaxDepth: -1, Match: And(newer, Not(Or(TypeIs('d'), TypeIs('l'))))}, []string{"/src/pkg/util.go", "/src/README.md"}},
+		{"or", FindOptions{MaxDepth: -1, Match: Or(TypeIs('l'), NameIs("pkg"))}, []string{"/src/pkg", "/src/link"}},
+		{"follow", FindOptions{FollowLinks: true, MinDepth: 1, MaxDepth: 1, Match: TypeIs('f')}, []string{"/src/main.go", "/src/README.md", "/src/link"}},
+	}
+	for _, c := range cases {
+		if got := all(c.opts); !slices.Equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if _, err := NameMatches("[", false); err != ETIBadPattern {
+		t.Fatalf("expected a bad pattern, got %v", err)
+	}
+
+	if nodes, err := tree.SearchAll("util.go"); err != nil || len(nodes) != 1 || nodes[0] != mustFollow(t, tree, "/src/pkg/util.go") {
+		t.Fatalf("unexpected SearchAll %v %v", nodes, err)
+	}
+}
@@ -0,0 +1,157 @@
+package tree
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Options for FromFS.
+*/
+type FromFSOptions struct {
+	// Reads the content of every file into the tree. Files are created empty otherwise.
+	Content bool
+	// Maximum depth of the imported nodes, the children of root being at depth 1. Zero means no limit.
+	MaxDepth int
+	// Patterns (in path.Match syntax) of the entries to be skipped. A pattern is matched against the entry's name and against its path relative
+	// to root, so "*.o" skips every object file and "build/cache" skips only that folder.
+	Exclude []string
+}
+
+/*
+Returns true if the entry at rel (relative to the import root) matches one of the exclusion patterns.
+*/
+func (opts FromFSOptions) excluded(rel string) (bool, error) {
+	for _, pattern := range opts.Exclude {
+		for _, candidate := range []string{path.Base(rel), rel} {
+			matched, err := path.Match(pattern, candidate)
+			if err != nil {
+				return false, err
+			}
+
+			if matched {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+/*
+The part of fs.ReadLinkFS used by FromFS, declared here to keep supporting the Go versions that don't have it.
+*/
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+/*
+Builds a tree out of the directory root of fsys (use "." for the whole file system), such as the one returned by os.DirFS for a real directory.
+Only folders, regular files and symbolic links are imported, anything else (devices, sockets...) is skipped. Links are only imported if fsys has a
+ReadLink method (as os.DirFS does) and their targets are kept as they are. If root is a file the tree holds only that file.
+The modification times and permission bits are taken from fsys.
+*/
+func FromFS(fsys fs.FS, root string, opts FromFSOptions) (*Tree, error) {
+	t := CreateTree()
+	folders := map[string]*FolderNode{root: t.Root()}
+	infos := map[Node]fs.FileInfo{} // applied at the end, since adding children changes the folders' times
+
+	err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == root && d.IsDir() {
+			return nil
+		}
+
+		rel := path.Base(p)
+		parent := t.Root()
+		if p != root {
+			rel = strings.TrimPrefix(p, root+"/")
+			if root == "." {
+				rel = p
+			}
+			parent = folders[path.Dir(p)]
+		}
+
+		if skip, err := opts.excluded(rel); err != nil || skip {
+			if err == nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		depth := strings.Count(rel, "/") + 1
+		if d.IsDir() {
+			folder, err := parent.InsertFolder(d.Name())
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			folders[p] = folder
+			infos[folder] = info
+
+			if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			linkFS, ok := fsys.(readLinkFS)
+			if !ok {
+				return nil
+			}
+
+			target, err := linkFS.ReadLink(p)
+			if err != nil {
+				return err
+			}
+
+			if _, err := parent.InsertSymlink(d.Name(), filepath.ToSlash(target)); err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		file, err := parent.InsertFile(d.Name())
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+
+		if opts.Content {
+			content, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return err
+			}
+			file.Write(content)
+		}
+
+		infos[file] = info
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for node, info := range infos {
+		meta := metaOf(node)
+		meta.modTime = info.ModTime()
+		meta.mode = info.Mode().Perm()
+	}
+
+	return t, nil
+}
@@ -0,0 +1,47 @@
+package tree
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"repo/main.go":           {Data: []byte("package main")},
+		"repo/build/out.o":       {Data: []byte{0x7f}},
+		"repo/pkg/a/a.go":        {Data: []byte("package a")},
+		"repo/pkg/a/deep/b.go":   {Data: []byte("package b")},
+		"repo/pkg/a/generated.o": {Data: []byte{0x7f}},
+		"repo/docs/.keep":        {},
+	}
+
+	tree, err := FromFS(fsys, "repo", FromFSOptions{Content: true, MaxDepth: 3, Exclude: []string{"*.o", "build"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := tree.ReadFile("/main.go"); err != nil || string(content) != "package main" {
+		t.Fatalf("unexpected content %q (%v)", content, err)
+	}
+
+	for _, p := range []string{"/pkg/a/a.go", "/pkg/a/deep", "/docs/.keep"} {
+		if _, err := tree.FollowPath(p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+
+	for _, p := range []string{"/build", "/pkg/a/generated.o", "/pkg/a/deep/b.go"} {
+		if _, err := tree.FollowPath(p); err == nil {
+			t.Fatalf("%s should not have been imported", p)
+		}
+	}
+
+	empty, err := FromFS(fsys, ".", FromFSOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := empty.ReadFile("/repo/main.go"); len(content) != 0 {
+		t.Fatal("content should only be read when requested")
+	}
+}
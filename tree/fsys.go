@@ -0,0 +1,209 @@
+package tree
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+/*
+FS is a read-only view of a tree that implements fs.FS, fs.ReadDirFS, fs.ReadFileFS, fs.StatFS, fs.GlobFS and fs.SubFS, so the tree can be used
+with fs.WalkDir, template.ParseFS, http.FS and anything else built on io/fs.
+
+Unlike the Tree methods, FS follows the io/fs naming rules: paths are unrooted, slash separated and cannot hold "." or ".." elements (see fs.ValidPath).
+The view is live, changes made to the tree are seen by it.
+*/
+type FS struct {
+	tree *Tree
+	dir  string // folder the view is rooted at, relative to the tree's root ("." for the root)
+}
+
+/*
+Returns an io/fs view of the whole tree.
+*/
+func (t *Tree) FS() *FS {
+	return &FS{tree: t, dir: "."}
+}
+
+/*
+Returns the node at name, which must be a valid io/fs path, after checking that the user of the tree has the permissions in want on it. Errors
+are returned as *fs.PathError.
+*/
+func (f *FS) node(op string, name string, want fs.FileMode) (Node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	node, err := f.tree.follow(path.Join(f.dir, name))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if err := f.tree.checkAccess(node, want); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return node, nil
+}
+
+/*
+Implements fs.FS. The returned file is a *File opened for reading only.
+*/
+func (f *FS) Open(name string) (fs.File, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	node, err := f.node("open", name, accessRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return openNode(f.tree, node, name, os.O_RDONLY), nil
+}
+
+/*
+Implements fs.ReadDirFS. Entries are sorted by name.
+*/
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	node, err := f.node("readdir", name, accessRead)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := node.AsFolder()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	folder.meta.accessed(folder.now())
+	return dirEntries(folder), nil
+}
+
+/*
+Implements fs.ReadFileFS.
+*/
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	f.tree.mu.Lock()
+	defer f.tree.mu.Unlock()
+
+	node, err := f.node("read", name, accessRead)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := node.AsFile()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	file.meta.accessed(file.now())
+	return file.Content(), nil
+}
+
+/*
+Implements fs.StatFS.
+*/
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	f.tree.mu.RLock()
+	defer f.tree.mu.RUnlock()
+
+	node, err := f.node("stat", name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Stat().renamed(path.Base(name)), nil
+}
+
+/*
+Returns the target of the link at name. Along with Lstat it implements fs.ReadLinkFS on the Go versions that have it.
+*/
+func (f *FS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+
+	target, err := f.tree.Readlink(path.Join(f.dir, name))
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+
+	return target, nil
+}
+
+/*
+Same as Stat, except that a link at name is described itself instead of being followed.
+*/
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := f.tree.Lstat(path.Join(f.dir, name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+
+	return info.renamed(path.Base(name)), nil
+}
+
+/*
+Implements fs.GlobFS, with the pattern syntax of Tree.Glob (which extends the one of path.Match with braces and **). Patterns that are not valid
+io/fs paths, such as the ones holding ".." steps or starting with a slash, are malformed patterns: ETIBadPattern is returned, which matches
+path.ErrBadPattern as fs.Glob does.
+*/
+func (f *FS) Glob(pattern string) ([]string, error) {
+	f.tree.mu.RLock()
+	defer f.tree.mu.RUnlock()
+
+	if !fs.ValidPath(pattern) {
+		return nil, ETIBadPattern
+	}
+
+	dir, err := f.node("glob", ".", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.tree.glob(dir, ".", pattern)
+}
+
+/*
+Implements fs.SubFS. The returned view is rooted at the folder dir.
+*/
+func (f *FS) Sub(dir string) (fs.FS, error) {
+	f.tree.mu.RLock()
+	defer f.tree.mu.RUnlock()
+
+	node, err := f.node("sub", dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if !node.IsFolder() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: ETIExpectedFolderFoundFile}
+	}
+
+	return &FS{tree: f.tree, dir: path.Join(f.dir, dir)}, nil
+}
+
+/*
+Returns the children of folder as directory entries, sorted by name.
+*/
+func dirEntries(folder *FolderNode) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, folder.children.len())
+	for child := range folder.children.all() {
+		entries = append(entries, fs.FileInfoToDirEntry(child.Stat()))
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries
+}
@@ -0,0 +1,77 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+)
+
+func createFSTree() *Tree {
+	tree := CreateTree()
+	tree.CreateFolder("/templates/partials", "empty", true)
+	tree.WriteFile("/templates/hello.tmpl", []byte("Hello {{.}}!"))
+	tree.WriteFile("/templates/partials/footer.tmpl", []byte("bye"))
+	tree.WriteFile("/README.md", []byte("# readme"))
+	return tree
+}
+
+func TestFSConformance(t *testing.T) {
+	tree := createFSTree()
+	if err := fstest.TestFS(tree.FS(), "README.md", "templates/hello.tmpl", "templates/partials/footer.tmpl", "templates/partials/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := tree.FS().Sub("templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(sub, "hello.tmpl", "partials/footer.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSWithStandardLibrary(t *testing.T) {
+	fsys := createFSTree().FS()
+
+	var walked []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(walked, ",") != ".,README.md,templates,templates/hello.tmpl,templates/partials,templates/partials/empty,templates/partials/footer.tmpl" {
+		t.Fatalf("unexpected walk %v", walked)
+	}
+
+	tmpl, err := template.ParseFS(fsys, "templates/*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.ExecuteTemplate(&out, "hello.tmpl", "tree"); err != nil || out.String() != "Hello tree!" {
+		t.Fatalf("unexpected template output %q (%v)", out.String(), err)
+	}
+
+	if _, err := fsys.Open("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	if _, err := fsys.Open("/README.md"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("expected fs.ErrInvalid, got %v", err)
+	}
+
+	for _, pattern := range []string{"/*.md", "../*", "templates/./*", "["} {
+		if matches, err := fs.Glob(fsys, pattern); !errors.Is(err, path.ErrBadPattern) {
+			t.Errorf("%q: expected path.ErrBadPattern, got %v (%v)", pattern, matches, err)
+		}
+	}
+}
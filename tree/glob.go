@@ -0,0 +1,202 @@
+package tree
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+/*
+Returns true if pattern holds any of the characters with a special meaning for Glob, i.e. if expanding it may give something else than the
+pattern itself.
+*/
+func HasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[{\`)
+}
+
+/*
+Returns the paths of every node matching pattern, sorted and without duplicates. Relative patterns start at the root, like every other path
+given to the tree. Each step of the pattern is matched against the names of the nodes as in a shell: * matches any sequence of characters, ?
+any single character and [abc] one of the characters in the class, which may hold ranges (a-z) and be negated with ! or ^. {a,b} matches either
+a or b, its alternatives may hold bars and further patterns and can be nested. A whole ** step matches any amount (including none) of folders,
+and a trailing ** everything under the folder. A backslash matches the character after it literally.
+
+Links are followed everywhere but in the folders crossed by **, so links to folders never make it loop. Folders the user cannot read are
+silently skipped. A malformed pattern gives ETIBadPattern, while a pattern that matches nothing gives an empty list.
+*/
+func (t *Tree) Glob(pattern string) ([]string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.glob(t.Root(), "/", pattern)
+}
+
+/*
+Matches pattern from the folder start, which is reported as base. The matches are paths relative to base, joined to it.
+*/
+func (t *Tree) glob(start Node, base string, pattern string) ([]string, error) {
+	alternatives := expandBraces(pattern)
+	compiled := make([][]string, 0, len(alternatives))
+	for _, alternative := range alternatives {
+		steps := splitPath(alternative)
+		for i, step := range steps {
+			if step == "**" {
+				continue
+			}
+
+			steps[i] = shellClasses(step)
+			if _, err := path.Match(steps[i], ""); err != nil {
+				return nil, ETIBadPattern
+			}
+		}
+		compiled = append(compiled, steps)
+	}
+
+	matches := map[string]bool{}
+	for _, steps := range compiled {
+		t.globSteps(start, base, steps, matches)
+	}
+
+	return slices.Sorted(maps.Keys(matches)), nil
+}
+
+/*
+Adds to matches the paths of the nodes reached by following steps from n, found at nodePath.
+*/
+func (t *Tree) globSteps(n Node, nodePath string, steps []string, matches map[string]bool) {
+	if len(steps) == 0 {
+		matches[nodePath] = true
+		return
+	}
+
+	folder, ok := n.(*FolderNode)
+	if !ok || t.checkAccess(folder, accessExec) != nil {
+		return
+	}
+
+	step, rest := steps[0], steps[1:]
+	switch {
+	case step == "**":
+		if len(rest) == 0 {
+			rest = []string{"*"}
+		}
+
+		t.globSteps(folder, nodePath, rest, matches)
+		if t.checkAccess(folder, accessRead) != nil {
+			return
+		}
+
+		for child := range folder.children.all() {
+			if child.IsFolder() {
+				t.globSteps(child, path.Join(nodePath, child.CleanName()), steps, matches)
+			}
+		}
+
+	case !HasGlobMeta(step):
+		// Plain steps are looked up, so they can also be "..", and the last one is not followed so dangling links still match.
+		if child, err := t.lookup([]string{step}, folder, len(rest) > 0, &linkWalk{}); err == nil {
+			t.globSteps(child, path.Join(nodePath, step), rest, matches)
+		}
+
+	default:
+		if t.checkAccess(folder, accessRead) != nil {
+			return
+		}
+
+		for child := range folder.children.all() {
+			if matched, _ := path.Match(step, child.CleanName()); !matched {
+				continue
+			}
+
+			childPath := path.Join(nodePath, child.CleanName())
+			if link, isLink := child.(*SymlinkNode); isLink && len(rest) > 0 {
+				target, err := t.resolveLink(link, &linkWalk{})
+				if err != nil {
+					continue
+				}
+				child = target
+			}
+
+			t.globSteps(child, childPath, rest, matches)
+		}
+	}
+}
+
+/*
+Expands the first brace group of pattern holding a comma and, recursively, the groups left in each alternative. Braces that are not closed or
+hold no comma are kept as they are, as shells do.
+*/
+func expandBraces(pattern string) []string {
+	for open := 0; open < len(pattern); open++ {
+		switch pattern[open] {
+		case '\\':
+			open++
+		case '{':
+			alternatives, end := braceAlternatives(pattern, open)
+			if len(alternatives) < 2 {
+				continue
+			}
+
+			expanded := []string{}
+			for _, alternative := range alternatives {
+				expanded = append(expanded, expandBraces(pattern[:open]+alternative+pattern[end+1:])...)
+			}
+			return expanded
+		}
+	}
+
+	return []string{pattern}
+}
+
+/*
+Splits the brace group opening at pattern[open] into its top level alternatives, returning them along with the position of the closing brace.
+No alternatives are returned if the group is never closed.
+*/
+func braceAlternatives(pattern string, open int) ([]string, int) {
+	depth, start := 0, open+1
+	alternatives := []string{}
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return append(alternatives, pattern[start:i]), i
+			}
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return nil, -1
+}
+
+/*
+Rewrites the shell negated classes of step ([!abc]) in the syntax of path.Match ([^abc]).
+*/
+func shellClasses(step string) string {
+	rewritten := []byte(step)
+	inClass := false
+	for i := 0; i < len(rewritten); i++ {
+		switch {
+		case rewritten[i] == '\\':
+			i++
+		case rewritten[i] == '[' && !inClass:
+			inClass = true
+			if i+1 < len(rewritten) && rewritten[i+1] == '!' {
+				rewritten[i+1] = '^'
+				i++
+			}
+		case rewritten[i] == ']' && inClass:
+			inClass = false
+		}
+	}
+
+	return string(rewritten)
+}
@@ -0,0 +1,89 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"testing"
+)
+
+/*
+Builds /src/{main.go, util.go, README.md}, /src/pkg/{a.go, b_test.go}, /docs/{guide.md, api.md} and a link /src/pkg/up -> /src.
+*/
+func globTree(t *testing.T) *Tree {
+	t.Helper()
+	tree := CreateTree()
+	tree.CreateFolder("/src", "pkg", true)
+	tree.CreateFolder("/", "docs", false)
+	for _, p := range []string{"/src/main.go", "/src/util.go", "/src/README.md", "/src/pkg/a.go", "/src/pkg/b_test.go", "/docs/guide.md", "/docs/api.md"} {
+		if err := tree.Touch(p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	tree.Symlink("/src", "/src/pkg/up")
+	return tree
+}
+
+func TestGlob(t *testing.T) {
+	tree := globTree(t)
+
+	cases := map[string][]string{
+		"/src/*.go":                     {"/src/main.go", "/src/util.go"},
+		"src/?ain.go":                   {"/src/main.go"},
+		"/src/[a-m]*":                   {"/src/main.go"},
+		"/src/[!a-m]*":                  {"/src/README.md", "/src/pkg", "/src/util.go"},
+		"/{src,docs}/*.md":              {"/docs/api.md", "/docs/guide.md", "/src/README.md"},
+		"/src/{main,pkg/{a,b_test}}.go": {"/src/main.go", "/src/pkg/a.go", "/src/pkg/b_test.go"},
+		"/**/*_test.go":                 {"/src/pkg/b_test.go"},
+		"/src/**":                       {"/src/README.md", "/src/main.go", "/src/pkg", "/src/pkg/a.go", "/src/pkg/b_test.go", "/src/pkg/up", "/src/util.go"},
+		"/src/pkg/up/*.go":              {"/src/pkg/up/main.go", "/src/pkg/up/util.go"},
+		"/src/pkg/../*.md":              {"/src/README.md"},
+		"/docs/\\*":                     {},
+		"/nothing/*":                    {},
+		"/{a,b":                         {},
+		"/docs/{guide}.md":              {},
+	}
+	for pattern, want := range cases {
+		got, err := tree.Glob(pattern)
+		if err != nil {
+			t.Errorf("%s: %v", pattern, err)
+			continue
+		}
+
+		if !slices.Equal(got, want) && !(len(got) == 0 && len(want) == 0) {
+			t.Errorf("%s: got %v, want %v", pattern, got, want)
+		}
+	}
+
+	for _, pattern := range []string{"/src/[", "/nothing/[]", "/{a,[}"} {
+		if _, err := tree.Glob(pattern); err != ETIBadPattern || !errors.Is(err, path.ErrBadPattern) {
+			t.Errorf("%s: expected a bad pattern, got %v", pattern, err)
+		}
+	}
+}
+
+func TestGlobPermissionsAndFS(t *testing.T) {
+	tree := globTree(t)
+	tree.Chmod("/docs", 0o700)
+	tree.SetUser(alice)
+
+	if got, _ := tree.Glob("/*/*.md"); !slices.Equal(got, []string{"/src/README.md"}) {
+		t.Fatalf("an unreadable folder was listed: %v", got)
+	}
+
+	tree.SetUser(RootUser)
+	sub, err := fs.Sub(tree.FS(), "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fs.Glob(sub, "{pkg/a,*}.go")
+	if err != nil || !slices.Equal(got, []string{"main.go", "pkg/a.go", "util.go"}) {
+		t.Fatalf("unexpected io/fs glob %v %v", got, err)
+	}
+
+	if got, err := fs.Glob(sub, "../docs/*"); !errors.Is(err, path.ErrBadPattern) || len(got) != 0 {
+		t.Fatalf("the io/fs glob escaped its folder: %v %v", got, err)
+	}
+}
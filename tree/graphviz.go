@@ -0,0 +1,153 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Options that change how a tree is drawn by Graphviz.
+*/
+type GraphVizOptions struct {
+	// Direction of the graph layout: TB (the default when empty), LR, BT or RL.
+	RankDir string
+	// Draws every subfolder (and its content) inside a box, using subgraph cluster_* blocks.
+	Cluster bool
+	// Paths of the nodes to be highlighted.
+	Highlight []string
+}
+
+const (
+	graphVizFolderShape  = "folder"
+	graphVizFileShape    = "note"
+	graphVizSymlinkShape = "cds" // the target is kept in the tooltip
+	graphVizHighlight    = `style=filled, fillcolor="#ffd966", color="#cc0000"`
+)
+
+/*
+Returns the absolute path of a node ("/" for the root, "/a/b" otherwise), which is unique within a tree.
+*/
+func absolutePath(n Node) string {
+	p := ""
+	for current := n; current != nil && current.Parent() != nil; current = current.Parent() {
+		p = "/" + current.CleanName() + p
+	}
+
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+/*
+Quotes s as a DOT ID. Node names cannot contain backslashes, so double quotes are the only characters that need escaping.
+*/
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+/*
+Returns the DOT statement declaring n. Nodes are identified by their absolute path and labeled by their name.
+*/
+func graphVizNode(n Node, highlighted map[Node]bool) string {
+	label := n.CleanName()
+	if n.Parent() == nil {
+		label = "/"
+	}
+
+	shape := graphVizFileShape
+	if n.IsFolder() {
+		shape = graphVizFolderShape
+	}
+
+	attrs := fmt.Sprintf("label=%s, shape=%s", dotQuote(label), shape)
+	if link, ok := n.(*SymlinkNode); ok {
+		attrs = fmt.Sprintf("label=%s, shape=%s, tooltip=%s", dotQuote(label), graphVizSymlinkShape, dotQuote(link.Target()))
+	}
+	if highlighted[n] {
+		attrs += ", " + graphVizHighlight
+	}
+
+	return fmt.Sprintf("%s [%s];\n", dotQuote(absolutePath(n)), attrs)
+}
+
+/*
+Writes the declarations of the children of fn (and their descendants) to sb, wrapping subfolders in clusters if requested.
+*/
+func (fn *FolderNode) graphVizNodes(sb *strings.Builder, indent string, cluster bool, highlighted map[Node]bool) {
+	for c := range fn.children.all() {
+		folder, isFolder := c.(*FolderNode)
+		if !isFolder || !cluster {
+			sb.WriteString(indent + graphVizNode(c, highlighted))
+			if isFolder {
+				folder.graphVizNodes(sb, indent, cluster, highlighted)
+			}
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("%ssubgraph %s {\n", indent, dotQuote("cluster_"+absolutePath(c))))
+		sb.WriteString(fmt.Sprintf("%s\tlabel=%s;\n", indent, dotQuote(c.CleanName())))
+		sb.WriteString(indent + "\t" + graphVizNode(c, highlighted))
+		folder.graphVizNodes(sb, indent+"\t", cluster, highlighted)
+		sb.WriteString(indent + "}\n")
+	}
+}
+
+/*
+Writes the edges between fn and its children (and between its descendants) to sb.
+*/
+func (fn *FolderNode) graphVizEdges(sb *strings.Builder, indent string) {
+	for c := range fn.children.all() {
+		sb.WriteString(fmt.Sprintf("%s%s -> %s;\n", indent, dotQuote(absolutePath(fn)), dotQuote(absolutePath(c))))
+		if folder, ok := c.(*FolderNode); ok {
+			folder.graphVizEdges(sb, indent)
+		}
+	}
+}
+
+/*
+Returns the DOT statements (node declarations followed by edges) describing the folder and all of its descendants, ready to be placed inside a
+digraph block. Every node is identified by its absolute path, so nodes with the same name in different folders are kept apart, and labeled by its name.
+*/
+func (fn *FolderNode) GraphVizOutput() string {
+	var sb strings.Builder
+	sb.WriteString(graphVizNode(fn, nil))
+	fn.graphVizNodes(&sb, "", false, nil)
+	fn.graphVizEdges(&sb, "")
+	return sb.String()
+}
+
+/*
+Returns the whole tree as a Graphviz DOT document. Folders and files are drawn with different shapes and can be read back by ParseDOT.
+*/
+func (t *Tree) GraphViz(opts GraphVizOptions) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	highlighted := map[Node]bool{}
+	for _, p := range opts.Highlight {
+		node, err := t.follow(p)
+		if err != nil {
+			return "", err
+		}
+		highlighted[node] = true
+	}
+
+	var sb strings.Builder
+	sb.WriteString("digraph G {\n")
+
+	switch strings.ToUpper(opts.RankDir) {
+	case "", "TB":
+	case "LR", "BT", "RL":
+		sb.WriteString("\trankdir=" + strings.ToUpper(opts.RankDir) + ";\n")
+	default:
+		return "", ETIInvalidRankDir
+	}
+
+	sb.WriteString("\t" + graphVizNode(t.Root(), highlighted))
+	t.Root().graphVizNodes(&sb, "\t", opts.Cluster, highlighted)
+	t.Root().graphVizEdges(&sb, "\t")
+	sb.WriteString("}\n")
+
+	return sb.String(), nil
+}
@@ -0,0 +1,52 @@
+package tree
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphVizRoundTrip(t *testing.T) {
+	original := CreateTree()
+	original.CreateFolder("/home/user", "desktop", true)
+	original.CreateFolder("/", "lib", false)
+	original.CreateFolder("/", "empty", false)
+	original.CreateFile("/home/user/desktop", "image.png")
+	original.CreateFile("/lib", "image.png")
+
+	for _, opts := range []GraphVizOptions{{}, {RankDir: "lr", Cluster: true, Highlight: []string{"/lib/image.png"}}} {
+		graph, err := original.GraphViz(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Count(graph, `[label="image.png", shape=note`) != 2 {
+			t.Fatalf("files with the same name should be kept apart:\n%s", graph)
+		}
+
+		loaded, err := ParseDOT(strings.NewReader(graph))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range []string{"/home/user/desktop/image.png", "/lib/image.png"} {
+			if node, err := loaded.FollowPath(p); err != nil || !node.IsFile() {
+				t.Fatalf("%s: expected a file (%v)", p, err)
+			}
+		}
+
+		if node, err := loaded.FollowPath("/empty"); err != nil || !node.IsFolder() {
+			t.Fatalf("/empty: expected a folder (%v)", err)
+		}
+	}
+}
+
+func TestGraphVizOptionErrors(t *testing.T) {
+	tree := CreateTree()
+	if _, err := tree.GraphViz(GraphVizOptions{RankDir: "up"}); err != ETIInvalidRankDir {
+		t.Fatalf("expected ETIInvalidRankDir, got %v", err)
+	}
+
+	if _, err := tree.GraphViz(GraphVizOptions{Highlight: []string{"/missing"}}); err == nil {
+		t.Fatal("highlighting a missing path should fail")
+	}
+}
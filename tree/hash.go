@@ -0,0 +1,136 @@
+package tree
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+/*
+Hash is a SHA-256 digest, naming the content of a node (see Node.Hash) or an object of a repository.
+*/
+type Hash [sha256.Size]byte
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+/*
+Returns the abbreviated form of the hash shown to users, which is usually enough to name a commit (see Resolve).
+*/
+func (h Hash) Short() string {
+	return h.String()[:7]
+}
+
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+/*
+Returns the Merkle hash of n, which covers everything about it but its name, inode number and timestamps: its kind, permission bits, owner,
+group and attributes, plus the content of a file, the target of a link or the names and hashes of the children of a folder, sorted by name. Two
+nodes with the same hash are the same subtree, wherever they are.
+
+Hashes are kept by the inodes and computed again only after the node (or, for folders, something inside it) changes, so hashing a tree that
+barely changed only goes through the changed paths.
+*/
+func nodeHash(n Node) Hash {
+	var epoch uint64
+	if t := ownerOf(n); t != nil {
+		epoch = t.hashEpoch
+	}
+
+	return hashNode(n, epoch)
+}
+
+/*
+Computes the hash of n (see nodeHash), reusing the hashes of the folders computed during epoch.
+*/
+func hashNode(n Node, epoch uint64) Hash {
+	in := inodeOf(n)
+	if in.hashed && (!n.IsFolder() || in.hashEpoch == epoch) {
+		return in.hash
+	}
+
+	h := sha256.New()
+	meta := metaOf(n)
+	fmt.Fprintf(h, "%c %o %q %q\n", entryKind(n), meta.mode, meta.owner, meta.group)
+	for _, key := range slices.Sorted(maps.Keys(meta.attrs)) {
+		fmt.Fprintf(h, "attr %q %q\n", key, meta.attrs[key])
+	}
+
+	switch node := n.(type) {
+	case *FileNode:
+		h.Write(node.content)
+	case *SymlinkNode:
+		h.Write([]byte(node.target))
+	case *FolderNode:
+		children := slices.SortedFunc(node.children.all(), func(a, b Node) int { return cmp.Compare(a.CleanName(), b.CleanName()) })
+		for _, child := range children {
+			childHash := hashNode(child, epoch)
+			fmt.Fprintf(h, "%q %x\n", child.CleanName(), childHash[:])
+		}
+	}
+
+	in.hash, in.hashed, in.hashEpoch = Hash(h.Sum(nil)), true, epoch
+	return in.hash
+}
+
+func (fn *FolderNode) Hash() Hash  { return nodeHash(fn) }
+func (fn *FileNode) Hash() Hash    { return nodeHash(fn) }
+func (sn *SymlinkNode) Hash() Hash { return nodeHash(sn) }
+
+/*
+Drops the hash of n and of the folders above it, which depend on it. Called whenever n changes (see touch). When a file with several hard links
+changes, only the folders above the link it changed through are known, so the epoch of its tree is bumped instead, which drops the hashes of
+every folder of that tree (and of its detached nodes) computed before. Other trees, including snapshots and clones, keep theirs.
+*/
+func invalidateHash(n Node) {
+	if file, ok := n.(*FileNode); ok && file.nlink > 1 {
+		if t := ownerOf(n); t != nil {
+			t.hashEpoch++
+		}
+	}
+
+	for in, parent := inodeOf(n), n.Parent(); in.hashed; in, parent = parent.inode, parent.Parent() {
+		in.hashed = false
+		if parent == nil {
+			break
+		}
+	}
+}
+
+/*
+Gives the copies of the inodes of src made into dst (see copyFrom) the hashes src still has, so hashing a copy of a tree, such as a snapshot,
+does not start over.
+*/
+func (c inodeCopies) keepHashes(src *Tree, dst *Tree) {
+	for in, copied := range c {
+		if in.hashed && in.hashEpoch == src.hashEpoch {
+			copied.hash, copied.hashed, copied.hashEpoch = in.hash, true, dst.hashEpoch
+		}
+	}
+}
+
+/*
+Returns the hash of the node at path (see Node.Hash), following links. The user must be able to read the node and, for folders, everything
+inside it.
+*/
+func (t *Tree) Hash(path string) (Hash, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if err := t.checkReadAll(node); err != nil {
+		return Hash{}, err
+	}
+
+	return node.Hash(), nil
+}
@@ -0,0 +1,98 @@
+package tree
+
+import (
+	"testing"
+)
+
+func TestMerkleHashes(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/src/pkg", "util", true)
+	tree.WriteFile("/src/pkg/util/a.go", []byte("package util"))
+	tree.WriteFile("/src/main.go", []byte("package main"))
+	tree.Copy("/src", "/copy", true)
+
+	if tree.Root().Hash() != tree.Root().Hash() {
+		t.Fatal("hashing twice should give the same hash")
+	}
+
+	src, copied := mustFollow(t, tree, "/src"), mustFollow(t, tree, "/copy")
+	if src.Hash() != copied.Hash() {
+		t.Fatal("identical subtrees should have the same hash")
+	}
+
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{"write", func() { tree.WriteFile("/src/pkg/util/a.go", []byte("package util // changed")) }},
+		{"chmod", func() { tree.Chmod("/src/pkg/util/a.go", 0o600) }},
+		{"attr", func() { tree.SetAttr("/src/pkg", "lang", "go") }},
+		{"rename", func() { tree.Rename("/src/main.go", "app.go") }},
+		{"create", func() { tree.CreateFile("/src/pkg", "b.go") }},
+		{"link", func() { tree.Symlink("/src/main.go", "/src/pkg/link") }},
+	}
+
+	for _, c := range changes {
+		root, before, other := tree.Root().Hash(), src.Hash(), copied.Hash()
+		c.change()
+		if src.Hash() == before || tree.Root().Hash() == root {
+			t.Fatalf("%s: the change was not seen by the folders above it", c.name)
+		}
+
+		if !copied.(*FolderNode).hashed || copied.Hash() != other {
+			t.Fatalf("%s: the hash of an unrelated folder was dropped or changed", c.name)
+		}
+
+		tree.Undo()
+		if src.Hash() != before || tree.Root().Hash() != root {
+			t.Fatalf("%s: undoing the change did not restore the hashes", c.name)
+		}
+		tree.Redo()
+	}
+
+	if hash, err := tree.Hash("/copy"); err != nil || hash != copied.Hash() {
+		t.Fatalf("unexpected hash of the path: %v", err)
+	}
+}
+
+func TestMerkleHashesOfHardLinks(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "a", false)
+	tree.CreateFolder("/", "b", false)
+	tree.WriteFile("/a/file", []byte("one"))
+	tree.Link("/a/file", "/b/link")
+
+	b := mustFollow(t, tree, "/b")
+	before := b.Hash()
+	tree.WriteFile("/a/file", []byte("two")) // changes /b/link as well
+	if b.Hash() == before {
+		t.Fatal("the folder holding the other link should see the change")
+	}
+}
+
+func TestMerkleHashesOfOtherTreesAreKept(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/a", "b", true)
+	tree.WriteFile("/a/file", []byte("one"))
+	tree.Link("/a/file", "/a/b/link")
+	clone := tree.Clone()
+	other := CreateTree()
+	other.CreateFolder("/x", "y", true)
+
+	trees := []*Tree{clone, other}
+	for _, tr := range trees {
+		tr.Root().Hash()
+	}
+
+	tree.WriteFile("/a/file", []byte("two"))
+	for i, tr := range trees {
+		root := tr.Root()
+		if !root.hashed || root.inode.hashEpoch != tr.hashEpoch {
+			t.Fatalf("tree %d: writing to a hard link of another tree dropped the cached hashes", i)
+		}
+	}
+
+	if clone.Root().Hash() == tree.Root().Hash() {
+		t.Fatal("the hash of the tree holding the links did not change")
+	}
+}
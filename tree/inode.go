@@ -0,0 +1,224 @@
+package tree
+
+/*
+inode holds what a node is, as opposed to where it is: its number, metadata and (for files) content. The nodes themselves (FolderNode, FileNode
+and SymlinkNode) are directory entries, each one giving a name and a position in the tree to an inode. Several file entries can share the same
+inode, in which case they are hard links to the same file and a change made through one of them is seen through all the others.
+
+Every tree keeps a table of the inodes reachable from its root, indexed by number. An inode leaves the table when its last entry is removed
+(nlink reaches zero), and its content is freed as soon as no open File refers to it anymore.
+*/
+type inode struct {
+	ino     uint64
+	nlink   int
+	open    int // File handles opened on the inode and not closed yet
+	content []byte
+	shared  bool // content may also be held by a copy of the inode or by the journal, see unshare
+	meta    nodeMeta
+
+	hash      Hash // see nodeHash, valid if hashed is set
+	hashed    bool
+	hashEpoch uint64
+}
+
+func newInode(meta nodeMeta) *inode {
+	return &inode{nlink: 1, meta: meta}
+}
+
+/*
+Returns the inode of a node.
+*/
+func inodeOf(n Node) *inode {
+	switch node := n.(type) {
+	case *FolderNode:
+		return node.inode
+	case *FileNode:
+		return node.inode
+	case *SymlinkNode:
+		return node.inode
+	}
+
+	panic("unknown node type")
+}
+
+/*
+Returns a copy of the inode. The copy keeps the number but has no links, it is up to the caller to count them. The content is only copied when
+one of the inodes changes it (see unshare), which keeps copying whole trees cheap.
+*/
+func (in *inode) clone() *inode {
+	in.shared = true
+	return &inode{
+		ino:     in.ino,
+		content: in.content,
+		shared:  true,
+		meta:    in.meta.clone(),
+	}
+}
+
+/*
+Copies the content of the inode if it may be held somewhere else, so it can be changed in place without changing the other holders.
+*/
+func (in *inode) unshare() {
+	if in.shared {
+		in.content = append([]byte(nil), in.content...)
+		in.shared = false
+	}
+}
+
+/*
+Frees the content of the inode if nothing refers to it anymore.
+*/
+func (in *inode) release() {
+	if in.nlink == 0 && in.open == 0 {
+		in.content = nil
+	}
+}
+
+/*
+Adds the inodes of n and its descendants to the table of the tree, giving new numbers to the ones that are not in it yet (such as copies or
+nodes coming from another tree). A registered node is being moved inside the tree, so its descendants are registered as well and are skipped.
+*/
+func (t *Tree) register(n Node) {
+	in := inodeOf(n)
+	if t.inodes[in.ino] == in {
+		return
+	}
+
+	if t.inodes == nil {
+		t.inodes = map[uint64]*inode{}
+	}
+
+	t.lastIno++
+	in.ino = t.lastIno
+	t.inodes[in.ino] = in
+
+	if folder, ok := n.(*FolderNode); ok {
+		for child := range folder.children.all() {
+			t.register(child)
+		}
+	}
+}
+
+/*
+Rebuilds the table from the nodes reachable from the root, keeping their numbers. Used when a whole tree is built at once (see Clone).
+*/
+func (t *Tree) reindex() {
+	t.inodes = map[uint64]*inode{}
+	var index func(n Node)
+	index = func(n Node) {
+		in := inodeOf(n)
+		t.inodes[in.ino] = in
+		t.lastIno = max(t.lastIno, in.ino)
+
+		if folder, ok := n.(*FolderNode); ok {
+			for child := range folder.children.all() {
+				index(child)
+			}
+		}
+	}
+
+	index(t.Root())
+}
+
+/*
+Drops the link n (and, for folders, every link inside it) held on its inode, freeing the inodes that are left without links. t is the tree n was
+removed from, or nil.
+*/
+func unlinkNode(n Node, t *Tree) {
+	t.touch(n, false)
+	in := inodeOf(n)
+	in.nlink--
+	if in.nlink > 0 && t != nil {
+		in.meta.changed(t.now())
+	}
+
+	if in.nlink == 0 {
+		if t != nil && t.inodes[in.ino] == in {
+			delete(t.inodes, in.ino)
+		}
+		in.release()
+	}
+
+	if folder, ok := n.(*FolderNode); ok {
+		for child := range folder.children.all() {
+			unlinkNode(child, t)
+		}
+	}
+}
+
+/*
+Adds a new entry named name to the current folder, as a hard link to file. Both entries share everything but their name and position.
+*/
+func (fn *FolderNode) InsertLink(name string, file *FileNode) (*FileNode, error) {
+	if err := ValidateNodeName(name); err != nil {
+		return nil, err
+	}
+
+	if fn.childNamed(name) != nil {
+		return nil, ETIDuplicatedName
+	}
+
+	link := &FileNode{name: name, parent: fn, inode: file.inode}
+	link.nlink++
+	link.meta.changed(fn.now())
+	fn.addChildren(link)
+	return link, nil
+}
+
+/*
+Creates newPath as a hard link to the file at oldPath, as os.Link does. Symbolic links at oldPath are followed and folders cannot be linked.
+*/
+func (t *Tree) Link(oldPath string, newPath string) (_ *FileNode, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "hard link %s to %s", newPath, oldPath)()
+
+	node, err := t.follow(oldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := node.AsFile()
+	if err != nil {
+		return nil, ETIHardLinkToFolder
+	}
+
+	steps := splitPath(newPath)
+	if len(steps) == 0 {
+		return nil, ETIDuplicatedName
+	}
+
+	parent, err := t.followPath(steps[:len(steps)-1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := parent.AsFolder()
+	if err != nil {
+		return nil, ETIExpectedFolderFoundFile
+	}
+
+	if err := t.checkModifyFolder(folder); err != nil {
+		return nil, err
+	}
+
+	t.touch(file, false)
+	return folder.InsertLink(steps[len(steps)-1], file)
+}
+
+/*
+Returns the amount of inodes in the table of the tree, i.e. the amount of distinct folders, files and links reachable from the root.
+*/
+func (t *Tree) InodeCount() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.inodes)
+}
+
+/*
+Returns true if a and b describe the same inode, as os.SameFile does.
+*/
+func SameFile(a *NodeInfo, b *NodeInfo) bool {
+	return a.inode == b.inode
+}
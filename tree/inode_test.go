@@ -0,0 +1,145 @@
+package tree
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestHardLinks(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "docs", false)
+	tree.WriteFile("/a.txt", []byte("hello"))
+
+	if _, err := tree.Link("/a.txt", "/docs/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	tree.AppendFile("/docs/b.txt", []byte(" world"))
+	if content, _ := tree.ReadFile("/a.txt"); string(content) != "hello world" {
+		t.Fatalf("the links do not share their content: %q", content)
+	}
+
+	a, _ := tree.Stat("/a.txt")
+	b, _ := tree.Stat("/docs/b.txt")
+	if !SameFile(a, b) || a.Ino() != b.Ino() || a.Nlink() != 2 {
+		t.Fatalf("unexpected link info %d/%d %d", a.Ino(), b.Ino(), a.Nlink())
+	}
+
+	tree.Chmod("/docs/b.txt", 0o600)
+	if info, _ := tree.Stat("/a.txt"); info.Mode() != 0o600 {
+		t.Fatalf("the links do not share their metadata: %v", info.Mode())
+	}
+
+	if err := tree.RemoveFile("/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := tree.ReadFile("/docs/b.txt"); err != nil || string(content) != "hello world" {
+		t.Fatalf("the content did not survive the first unlink: %q %v", content, err)
+	}
+
+	if info, _ := tree.Stat("/docs/b.txt"); info.Nlink() != 1 {
+		t.Fatalf("unexpected link count %d", info.Nlink())
+	}
+
+	if _, err := tree.Link("/docs", "/folder-link"); err != ETIHardLinkToFolder {
+		t.Fatalf("expected folders to be refused, got %v", err)
+	}
+
+	if _, err := tree.Link("/docs/b.txt", "/docs/b.txt"); err != ETIDuplicatedName {
+		t.Fatalf("expected a duplicated name, got %v", err)
+	}
+}
+
+func TestInodeTable(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/a", "b", true)
+	tree.WriteFile("/a/b/f", []byte("x"))
+	tree.Link("/a/b/f", "/g")
+
+	if count := tree.InodeCount(); count != 4 { // root, a, b and the shared file
+		t.Fatalf("unexpected inode count %d", count)
+	}
+
+	moved, _ := tree.Stat("/a/b")
+	tree.Move("/a/b", "/")
+	if info, _ := tree.Stat("/b"); info.Ino() != moved.Ino() || info.Nlink() != 1 {
+		t.Fatalf("a move changed the inode: %d %d", info.Ino(), info.Nlink())
+	}
+
+	copied, err := tree.Copy("/b", "/c", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info := copied.Stat(); info.Ino() == moved.Ino() || tree.InodeCount() != 6 {
+		t.Fatalf("a copy reused an inode: %d, %d inodes", info.Ino(), tree.InodeCount())
+	}
+
+	tree.RemoveFolder("/b", true)
+	tree.RemoveFolder("/c", true)
+	if info, _ := tree.Stat("/g"); info.Nlink() != 1 || tree.InodeCount() != 3 {
+		t.Fatalf("unexpected table after removing the folders: nlink %d, %d inodes", info.Nlink(), tree.InodeCount())
+	}
+
+	clone := tree.Clone()
+	original, _ := tree.Stat("/g")
+	if info, _ := clone.Stat("/g"); info.Ino() != original.Ino() || clone.InodeCount() != tree.InodeCount() || SameFile(info, original) {
+		t.Fatalf("unexpected clone inode %d", info.Ino())
+	}
+}
+
+func TestUnlinkWhileOpen(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/f", []byte("kept while open"))
+
+	f, err := tree.Open("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree.RemoveFile("/f")
+	content, err := io.ReadAll(f)
+	if err != nil || string(content) != "kept while open" {
+		t.Fatalf("the content was freed while open: %q %v", content, err)
+	}
+
+	if info, _ := f.Stat(); info.(*NodeInfo).Nlink() != 0 {
+		t.Fatalf("unexpected link count %d", info.(*NodeInfo).Nlink())
+	}
+
+	node := f.node.(*FileNode)
+	f.Close()
+	if node.content != nil {
+		t.Fatal("the content was not freed by the last close")
+	}
+}
+
+func TestHardLinksJSON(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/", "d", false)
+	tree.WriteFile("/a", []byte("shared"))
+	tree.Link("/a", "/d/b")
+	tree.Link("/a", "/d/c")
+
+	var buf bytes.Buffer
+	if err := tree.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := bytes.Count(buf.Bytes(), []byte(`"content"`)); count != 1 {
+		t.Fatalf("the shared content was written %d times", count)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := loaded.Stat("/a")
+	c, _ := loaded.Stat("/d/c")
+	if !SameFile(a, c) || c.Nlink() != 3 || c.Size() != 6 {
+		t.Fatalf("hard links lost in JSON: nlink %d size %d", c.Nlink(), c.Size())
+	}
+}
@@ -0,0 +1,450 @@
+package tree
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"time"
+)
+
+/*
+The journal records every operation made through the Tree methods that change the tree, so it can be undone and redone later as in an editor.
+Each entry holds what the operation did to the structure of the tree (which nodes it attached, detached and renamed) and the state of every
+inode it touched before and after it ran. Undoing an entry reverts the structure and puts the inodes back as they were before the operation,
+redoing it does the opposite. Neither checks permissions: the journal belongs to the tree, not to its current user.
+
+Recording also makes every Tree method atomic: an operation that fails is undone right away, so it never leaves the tree half changed. Several
+operations can be grouped into a single entry with a Transaction (see Begin).
+
+Only the Tree methods are recorded. The unchecked FolderNode and FileNode methods and writes made through a File are not, and undoing an entry
+whose nodes they changed in the meantime may fail with ETIJournalConflict.
+*/
+type journal struct {
+	done   []*journalEntry
+	undone []*journalEntry // the last one is the next to be redone
+	limit  int
+	active *journalEntry // the entry of the operation running right now, if any
+	tx     *Transaction  // the transaction the tree is the working copy of, which the operations are staged in
+	open   *Transaction  // the transaction opened on the tree, if any
+}
+
+/*
+The amount of operations a new tree keeps in its journal (see SetJournalLimit).
+*/
+const DefaultJournalLimit = 1000
+
+/*
+HistoryEntry describes an operation recorded in the journal: what it did and when.
+*/
+type HistoryEntry struct {
+	Op   string
+	Time time.Time
+}
+
+type journalEntry struct {
+	HistoryEntry
+	steps   []*journalEntry // the operations grouped in the entry, if it belongs to a transaction
+	changes []change
+	inodes  []*inode // touched by the operation, in the order they were first touched
+	nodes   []Node   // the node each of the inodes was first touched through
+	before  map[*inode]inodeState
+	after   map[*inode]inodeState
+}
+
+/*
+What an operation may change on an inode. The content is only copied when the operation changes it (owned), otherwise the slice is shared with
+the inode (which is marked as shared, see unshare), so the content of a removed file can be put back even if it was freed.
+*/
+type inodeState struct {
+	nlink   int
+	content []byte
+	owned   bool
+	meta    nodeMeta
+}
+
+/*
+A change made to the structure of the tree, which can be reverted (undo) and made again.
+*/
+type change interface {
+	apply(undo bool) error
+}
+
+/*
+node was attached to (or detached from) parent, right before the child next (at the end if nil), and named name.
+*/
+type placement struct {
+	node     Node
+	parent   *FolderNode
+	next     Node
+	name     string
+	attached bool
+}
+
+func (p placement) apply(undo bool) error {
+	if p.attached == undo {
+		if !p.parent.children.contains(p.node) {
+			return ETIJournalConflict
+		}
+
+		invalidateHash(p.parent)
+		p.parent.children.remove(p.node)
+		return nil
+	}
+
+	if p.parent.childNamed(p.name) != nil {
+		return ETIJournalConflict
+	}
+
+	invalidateHash(p.parent)
+	setNodeName(p.node, p.name)
+	setNodeParent(p.node, p.parent)
+	p.parent.children.insert(p.node, p.next)
+	return nil
+}
+
+/*
+node was renamed from from to to.
+*/
+type renaming struct {
+	node     Node
+	from, to string
+}
+
+func (r renaming) apply(undo bool) error {
+	name := r.to
+	if undo {
+		name = r.from
+	}
+
+	if parent := r.node.Parent(); parent != nil && parent.children.contains(r.node) {
+		if existing := parent.childNamed(name); existing != nil && existing != r.node {
+			return ETIJournalConflict
+		}
+	}
+
+	invalidateHash(r.node)
+	setNodeName(r.node, name)
+	return nil
+}
+
+/*
+Starts recording an operation described by format and args, returning the function that stops recording it. If the operation failed (i.e. *err
+is not nil by then) whatever it did is undone instead. Operations made while another one is being recorded are part of it.
+*/
+func (t *Tree) record(err *error, format string, args ...any) func() {
+	if t.journal.active != nil {
+		return func() {}
+	}
+
+	entry := &journalEntry{
+		HistoryEntry: HistoryEntry{Op: fmt.Sprintf(format, args...), Time: t.now()},
+		before:       map[*inode]inodeState{},
+	}
+
+	t.journal.active = entry
+	return func() {
+		t.journal.active = nil
+		if *err != nil {
+			t.replay(entry, true)
+			return
+		}
+		t.commit(entry)
+		t.notifyEntry(entry, false)
+	}
+}
+
+/*
+Returns the path of name inside the folder at dir, as shown in the history.
+*/
+func joinStep(dir string, name string) string {
+	return path.Join(dir, name)
+}
+
+/*
+Returns the tree n belongs to, or nil if it is not attached to one.
+*/
+func ownerOf(n Node) *Tree {
+	if folder, ok := n.(*FolderNode); ok {
+		return folder.owner()
+	}
+
+	if parent := n.Parent(); parent != nil {
+		return parent.owner()
+	}
+
+	return nil
+}
+
+/*
+Saves the state of the inode of n in the entry being recorded, if it was not saved yet. If content is true the content is going to change, so
+a copy of it is saved. Nodes that are not in the tree yet (such as copies) are saved as having no links. Every change goes through here, so
+it is also where the hash of n is dropped (see invalidateHash).
+*/
+func (t *Tree) touch(n Node, content bool) {
+	invalidateHash(n)
+	if t != nil {
+		t.version++
+	}
+
+	if t == nil || t.journal.active == nil {
+		return
+	}
+
+	entry := t.journal.active
+	in := inodeOf(n)
+	state, seen := entry.before[in]
+	if !seen {
+		state = inodeState{nlink: in.nlink, content: in.content, meta: in.meta.clone()}
+		if t.inodes[in.ino] != in {
+			state.nlink = 0
+		}
+		entry.inodes = append(entry.inodes, in)
+		entry.nodes = append(entry.nodes, n)
+		in.shared = true
+	}
+
+	if content && !state.owned {
+		state.content, state.owned = append([]byte(nil), in.content...), true
+	}
+
+	entry.before[in] = state
+}
+
+/*
+Saves the state of n and of everything inside it (see touch).
+*/
+func (t *Tree) touchAll(n Node) {
+	if t == nil || t.journal.active == nil {
+		return
+	}
+
+	t.touch(n, false)
+	if folder, ok := n.(*FolderNode); ok {
+		for child := range folder.children.all() {
+			t.touchAll(child)
+		}
+	}
+}
+
+/*
+Adds c to the entry being recorded.
+*/
+func (t *Tree) recordChange(c change) {
+	if t != nil {
+		t.version++
+	}
+
+	if t != nil && t.journal.active != nil {
+		t.journal.active.changes = append(t.journal.active.changes, c)
+	}
+}
+
+/*
+Saves the state the touched inodes were left in and adds the entry to the journal, or to the open transaction. Entries that touched nothing are
+dropped.
+*/
+func (t *Tree) commit(entry *journalEntry) {
+	if len(entry.inodes) == 0 && len(entry.changes) == 0 {
+		return
+	}
+
+	entry.after = make(map[*inode]inodeState, len(entry.inodes))
+	for _, in := range entry.inodes {
+		state := inodeState{nlink: in.nlink, content: in.content, meta: in.meta.clone()}
+		if entry.before[in].owned {
+			state.content, state.owned = append([]byte(nil), in.content...), true
+		}
+		entry.after[in] = state
+	}
+
+	if t.journal.tx != nil {
+		t.journal.tx.steps = append(t.journal.tx.steps, entry)
+		return
+	}
+
+	t.push(entry)
+}
+
+/*
+Adds entry to the journal, discarding the entries that were undone.
+*/
+func (t *Tree) push(entry *journalEntry) {
+	if t.journal.limit == 0 {
+		return
+	}
+
+	t.journal.done = append(t.journal.done, entry)
+	t.journal.undone = nil
+	t.trimJournal()
+}
+
+func (t *Tree) trimJournal() {
+	if extra := len(t.journal.done) - t.journal.limit; extra > 0 {
+		t.journal.done = slices.Delete(t.journal.done, 0, extra)
+	}
+}
+
+/*
+Reverts (undo) or makes again the structural changes of entry, then puts the touched inodes in the state they had before (undo) or after it.
+If a change cannot be made, the ones already made are reverted and ETIJournalConflict is returned.
+*/
+func (t *Tree) replay(entry *journalEntry, undo bool) error {
+	t.version++
+	if entry.steps != nil {
+		return t.replaySteps(entry.steps, undo)
+	}
+
+	changes := slices.Clone(entry.changes)
+	if undo {
+		slices.Reverse(changes)
+	}
+
+	for i, c := range changes {
+		if err := c.apply(undo); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				changes[j].apply(!undo)
+			}
+			return err
+		}
+	}
+
+	states := entry.after
+	if undo {
+		states = entry.before
+	}
+
+	for i, in := range entry.inodes {
+		invalidateHash(entry.nodes[i])
+		state := states[in]
+		in.nlink, in.meta, in.content, in.shared = state.nlink, state.meta.clone(), state.content, true
+		if state.owned {
+			in.content, in.shared = append([]byte(nil), state.content...), false
+		}
+
+		if in.nlink > 0 {
+			t.inodes[in.ino] = in
+		} else if t.inodes[in.ino] == in {
+			delete(t.inodes, in.ino)
+		}
+	}
+
+	return nil
+}
+
+/*
+Replays the entries in steps in order (or reverted in the opposite order if undo is true), as a single one.
+*/
+func (t *Tree) replaySteps(steps []*journalEntry, undo bool) error {
+	steps = slices.Clone(steps)
+	if undo {
+		slices.Reverse(steps)
+	}
+
+	for i, step := range steps {
+		if err := t.replay(step, undo); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				t.replay(steps[j], !undo)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Undoes the last operation recorded in the journal, returning its description. ETINothingToUndo is returned if there is none, and
+ETITransactionOpen while a transaction is open.
+*/
+func (t *Tree) Undo() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inTransaction() {
+		return "", ETITransactionOpen
+	}
+
+	if len(t.journal.done) == 0 {
+		return "", ETINothingToUndo
+	}
+
+	entry := t.journal.done[len(t.journal.done)-1]
+	if err := t.replay(entry, true); err != nil {
+		return "", err
+	}
+
+	t.journal.done = t.journal.done[:len(t.journal.done)-1]
+	t.journal.undone = append(t.journal.undone, entry)
+	t.notifyEntry(entry, true)
+	return entry.Op, nil
+}
+
+/*
+Makes again the last operation undone, returning its description. ETINothingToRedo is returned if there is none, which is also the case once
+a new operation is recorded after the undo, and ETITransactionOpen while a transaction is open.
+*/
+func (t *Tree) Redo() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inTransaction() {
+		return "", ETITransactionOpen
+	}
+
+	if len(t.journal.undone) == 0 {
+		return "", ETINothingToRedo
+	}
+
+	entry := t.journal.undone[len(t.journal.undone)-1]
+	if err := t.replay(entry, false); err != nil {
+		return "", err
+	}
+
+	t.journal.undone = t.journal.undone[:len(t.journal.undone)-1]
+	t.journal.done = append(t.journal.done, entry)
+	t.notifyEntry(entry, false)
+	return entry.Op, nil
+}
+
+/*
+Returns the operations in the journal: the ones that can be undone, oldest first, and the ones that can be redone, next to be redone first.
+*/
+func (t *Tree) History() (done []HistoryEntry, undone []HistoryEntry) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, entry := range t.journal.done {
+		done = append(done, entry.HistoryEntry)
+	}
+
+	for _, entry := range slices.Backward(t.journal.undone) {
+		undone = append(undone, entry.HistoryEntry)
+	}
+
+	return done, undone
+}
+
+/*
+Sets the amount of operations kept in the journal, dropping the oldest ones if there are more. A limit of 0 (or below) turns the journal off,
+dropping the operations that could be redone as well, though operations and transactions stay atomic.
+*/
+func (t *Tree) SetJournalLimit(limit int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.journal.limit = max(limit, 0)
+	t.trimJournal()
+	if t.journal.limit == 0 {
+		t.journal.undone = nil
+	}
+}
+
+/*
+Returns the amount of operations kept in the journal.
+*/
+func (t *Tree) JournalLimit() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.journal.limit
+}
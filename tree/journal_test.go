@@ -0,0 +1,184 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func saved(t *testing.T, tree *Tree) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tree.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestUndoRedo(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tree := CreateTreeWithClock(clock)
+	tree.CreateFolder("/docs", "drafts", true)
+	tree.WriteFile("/docs/a.txt", []byte("first"))
+	tree.WriteFile("/docs/drafts/b.txt", []byte("second"))
+	tree.Link("/docs/a.txt", "/a-link")
+	tree.Symlink("/docs", "/shortcut")
+
+	ops := []struct {
+		name string
+		run  func() error
+	}{
+		{"remove folder", func() error { return tree.RemoveFolder("/docs", true) }},
+		{"remove link", func() error { return tree.RemoveFile("/a-link") }},
+		{"move", func() error { return tree.Move("/docs/drafts", "/") }},
+		{"rename", func() error { return tree.Rename("/docs/a.txt", "renamed.txt") }},
+		{"copy", func() error { return errOf(tree.Copy("/docs", "/copy", true)) }},
+		{"write", func() error { return tree.WriteFile("/docs/a.txt", []byte("replaced")) }},
+		{"append", func() error { return tree.AppendFile("/a-link", []byte(" and more")) }},
+		{"truncate", func() error { return tree.Truncate("/docs/drafts/b.txt", 2) }},
+		{"chmod", func() error { return tree.Chmod("/docs", 0o700) }},
+		{"attr", func() error { return tree.SetAttr("/docs/a.txt", "k", "v") }},
+		{"create", func() error { return errOf(tree.CreateFolder("/new/deep", "leaf", true)) }},
+		{"link", func() error { return errOf(tree.Link("/docs/drafts/b.txt", "/b-link")) }},
+	}
+
+	for _, op := range ops {
+		clock.advance()
+		before, inodes := saved(t, tree), tree.InodeCount()
+		if err := op.run(); err != nil {
+			t.Fatalf("%s: %v", op.name, err)
+		}
+		after := saved(t, tree)
+
+		clock.advance()
+		if _, err := tree.Undo(); err != nil {
+			t.Fatalf("%s: undo: %v", op.name, err)
+		}
+
+		if state := saved(t, tree); state != before || tree.InodeCount() != inodes {
+			t.Errorf("%s: undo did not restore the tree:\n%s\nwant\n%s", op.name, state, before)
+		}
+
+		if _, err := tree.Redo(); err != nil {
+			t.Fatalf("%s: redo: %v", op.name, err)
+		}
+
+		if state := saved(t, tree); state != after {
+			t.Errorf("%s: redo did not restore the tree:\n%s\nwant\n%s", op.name, state, after)
+		}
+
+		tree.Undo()
+	}
+
+	if _, err := tree.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.FollowPath("/shortcut"); err != ETIPathNotFound {
+		t.Fatalf("the undos went past the operations they were meant to: %v", err)
+	}
+}
+
+func TestUndoKeepsInodes(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/a", []byte("shared"))
+	tree.Link("/a", "/b")
+	original, _ := tree.Stat("/a")
+
+	tree.RemoveFile("/a")
+	tree.RemoveFile("/b")
+	if tree.InodeCount() != 1 {
+		t.Fatalf("unexpected inode count %d", tree.InodeCount())
+	}
+
+	tree.Undo()
+	tree.Undo()
+	a, _ := tree.Stat("/a")
+	b, _ := tree.Stat("/b")
+	if !SameFile(a, b) || a.Ino() != original.Ino() || a.Nlink() != 2 || tree.InodeCount() != 2 {
+		t.Fatalf("the links were not restored: ino %d nlink %d", a.Ino(), a.Nlink())
+	}
+
+	if content, _ := tree.ReadFile("/b"); string(content) != "shared" {
+		t.Fatalf("the content was not restored: %q", content)
+	}
+
+	copied, _ := tree.Copy("/a", "/c", false)
+	tree.Undo()
+	tree.Redo()
+	if info, _ := tree.Stat("/c"); info.Ino() != copied.Stat().Ino() || tree.InodeCount() != 3 {
+		t.Fatalf("redo changed the inode of the copy: %d", info.Ino())
+	}
+}
+
+func TestJournalBookkeeping(t *testing.T) {
+	tree := CreateTree()
+	if _, err := tree.Undo(); err != ETINothingToUndo {
+		t.Fatalf("expected nothing to undo, got %v", err)
+	}
+
+	tree.CreateFile("/", "a")
+	tree.CreateFile("/", "a") // fails, so it is not recorded
+	tree.FollowPath("/a")
+	tree.CreateFile("/", "b")
+
+	done, undone := tree.History()
+	if len(done) != 2 || done[0].Op != "create file /a" || len(undone) != 0 {
+		t.Fatalf("unexpected history %v %v", done, undone)
+	}
+
+	if op, err := tree.Undo(); err != nil || op != "create file /b" {
+		t.Fatalf("unexpected undo %q %v", op, err)
+	}
+
+	tree.CreateFile("/", "c")
+	if _, err := tree.Redo(); err != ETINothingToRedo {
+		t.Fatalf("a new operation should discard the undone ones, got %v", err)
+	}
+
+	tree.SetJournalLimit(1)
+	if done, _ := tree.History(); len(done) != 1 || done[0].Op != "create file /c" {
+		t.Fatalf("the journal was not trimmed: %v", done)
+	}
+
+	tree.SetJournalLimit(0)
+	tree.CreateFile("/", "d")
+	if done, _ := tree.History(); len(done) != 0 {
+		t.Fatalf("a disabled journal recorded %v", done)
+	}
+
+	tree.SetJournalLimit(5)
+	tree.CreateFile("/", "e")
+	tree.Undo()
+	tree.SetJournalLimit(-1)
+	if tree.JournalLimit() != 0 {
+		t.Fatalf("a negative limit was kept as %d", tree.JournalLimit())
+	}
+
+	if _, err := tree.Redo(); err != ETINothingToRedo {
+		t.Fatalf("a disabled journal kept the undone operations, got %v", err)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/a", []byte("journaled"))
+	tree.RemoveFile("/a")
+	tree.Root().InsertFile("a") // not journaled
+
+	if _, err := tree.Undo(); err != ETIJournalConflict {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	if content, _ := tree.ReadFile("/a"); len(content) != 0 {
+		t.Fatalf("the conflicting undo changed the tree: %q", content)
+	}
+
+	tree.Root().RemoveNode("a")
+	if _, err := tree.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := tree.ReadFile("/a"); string(content) != "journaled" {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
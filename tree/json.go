@@ -0,0 +1,280 @@
+package tree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"strconv"
+	"time"
+)
+
+/*
+Version of the JSON format written by MarshalJSON. Load refuses documents written by newer versions.
+*/
+const JSONFormatVersion = 1
+
+/*
+The JSON document describing a whole tree.
+*/
+type jsonTree struct {
+	Version int       `json:"version"`
+	Root    *jsonNode `json:"root"`
+}
+
+/*
+The JSON representation of a single node. Names are stored clean (without the folder's trailing bar) and the content of files is base64 encoded
+by encoding/json. Missing timestamps (e.g. in documents written before they existed) are set to the time the document is loaded, and missing
+modes and owners get the defaults (0755 for folders and 0644 for files, owned by root). Modes are written in octal, as in "0755".
+
+Files with several hard links carry the number of their inode, which groups the entries of the same file: only the first of them holds the content
+and the metadata, the others are loaded as links to it.
+*/
+type jsonNode struct {
+	Type       string            `json:"type"`
+	Name       string            `json:"name"`
+	ModTime    time.Time         `json:"mtime"`
+	ChangeTime time.Time         `json:"ctime"`
+	AccessTime time.Time         `json:"atime"`
+	Attrs      map[string]string `json:"attrs,omitempty"`
+	Mode       string            `json:"mode,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+	Group      string            `json:"group,omitempty"`
+	Content    []byte            `json:"content,omitempty"`
+	Target     string            `json:"target,omitempty"`
+	Inode      uint64            `json:"inode,omitempty"`
+	Children   []*jsonNode       `json:"children,omitempty"`
+}
+
+const (
+	jsonTypeFolder  = "folder"
+	jsonTypeFile    = "file"
+	jsonTypeSymlink = "symlink"
+)
+
+/*
+Converts a node (and all of its descendants) into its JSON representation. seen holds the hard linked inodes already written.
+*/
+func toJSONNode(n Node, seen map[*inode]bool) *jsonNode {
+	meta := metaOf(n)
+	jn := &jsonNode{
+		Name:       n.CleanName(),
+		ModTime:    meta.modTime,
+		ChangeTime: meta.changeTime,
+		AccessTime: meta.accessTime,
+		Mode:       fmt.Sprintf("%04o", uint32(meta.mode)),
+		Owner:      meta.owner,
+		Group:      meta.group,
+	}
+
+	if len(meta.attrs) > 0 {
+		jn.Attrs = maps.Clone(meta.attrs)
+	}
+
+	switch node := n.(type) {
+	case *FolderNode:
+		jn.Type = jsonTypeFolder
+		for child := range node.children.all() {
+			jn.Children = append(jn.Children, toJSONNode(child, seen))
+		}
+	case *FileNode:
+		jn.Type = jsonTypeFile
+		if node.nlink > 1 {
+			jn.Inode = node.ino
+			if seen[node.inode] {
+				return &jsonNode{Type: jsonTypeFile, Name: jn.Name, Inode: jn.Inode}
+			}
+			seen[node.inode] = true
+		}
+		jn.Content = node.Content()
+	case *SymlinkNode:
+		jn.Type = jsonTypeSymlink
+		jn.Target = node.Target()
+	}
+
+	return jn
+}
+
+/*
+Copies the metadata in jn to the node. It must be called after the children of a folder are built, since adding them changes its times.
+*/
+func (jn *jsonNode) applyMeta(n Node) error {
+	meta := metaOf(n)
+	if jn.Mode != "" {
+		mode, err := strconv.ParseUint(jn.Mode, 8, 32)
+		if err != nil || fs.FileMode(mode)&^fs.ModePerm != 0 {
+			return ETIMalformedDocument
+		}
+		meta.mode = fs.FileMode(mode)
+	}
+
+	if jn.Owner != "" {
+		meta.owner = jn.Owner
+	}
+
+	if jn.Group != "" {
+		meta.group = jn.Group
+	}
+
+	for _, pair := range []struct {
+		from time.Time
+		to   *time.Time
+	}{{jn.ModTime, &meta.modTime}, {jn.ChangeTime, &meta.changeTime}, {jn.AccessTime, &meta.accessTime}} {
+		if !pair.from.IsZero() {
+			*pair.to = pair.from
+		}
+	}
+
+	for k, v := range jn.Attrs {
+		meta.attrs[k] = v
+	}
+
+	return nil
+}
+
+/*
+Rebuilds the children described by jn inside folder. Names are validated the same way as any other insertion. links maps the inode numbers of the
+document to the files already built for them.
+*/
+func (jn *jsonNode) buildChildren(folder *FolderNode, links map[uint64]*FileNode) error {
+	for _, child := range jn.Children {
+		if child == nil {
+			return ETIMalformedDocument
+		}
+
+		switch child.Type {
+		case jsonTypeFolder:
+			created, err := folder.InsertFolder(child.Name)
+			if err != nil {
+				return err
+			}
+
+			if err := child.buildChildren(created, links); err != nil {
+				return err
+			}
+			if err := child.applyMeta(created); err != nil {
+				return err
+			}
+		case jsonTypeFile:
+			if len(child.Children) > 0 {
+				return ETIMalformedDocument
+			}
+
+			if linked, ok := links[child.Inode]; ok {
+				changeTime := linked.meta.changeTime
+				if _, err := folder.InsertLink(child.Name, linked); err != nil {
+					return err
+				}
+				linked.meta.changeTime = changeTime
+				continue
+			}
+
+			created, err := folder.InsertFile(child.Name)
+			if err != nil {
+				return err
+			}
+
+			if child.Inode != 0 {
+				links[child.Inode] = created
+			}
+			created.Write(child.Content)
+			if err := child.applyMeta(created); err != nil {
+				return err
+			}
+		case jsonTypeSymlink:
+			if len(child.Children) > 0 {
+				return ETIMalformedDocument
+			}
+
+			created, err := folder.InsertSymlink(child.Name, child.Target)
+			if err != nil {
+				return err
+			}
+
+			if err := child.applyMeta(created); err != nil {
+				return err
+			}
+		default:
+			return ETIMalformedDocument
+		}
+	}
+
+	return nil
+}
+
+/*
+Implements json.Marshaler. The whole tree is written, starting by the root.
+*/
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	root := toJSONNode(t.Root(), map[*inode]bool{})
+	root.Name = ""
+
+	return json.Marshal(jsonTree{Version: JSONFormatVersion, Root: root})
+}
+
+/*
+Implements json.Unmarshaler. The current content of the tree is discarded and replaced by the one in the document. If the document is invalid the
+tree is left untouched.
+*/
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var doc jsonTree
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if doc.Version < 1 || doc.Version > JSONFormatVersion {
+		return ETIUnsupportedVersion
+	}
+
+	if doc.Root == nil || doc.Root.Type != jsonTypeFolder {
+		return ETIMalformedDocument
+	}
+
+	built := CreateTree()
+	if err := doc.Root.buildChildren(built.Root(), map[uint64]*FileNode{}); err != nil {
+		return err
+	}
+
+	if err := doc.Root.applyMeta(built.Root()); err != nil {
+		return err
+	}
+
+	events := t.rootEvents(EventRemove)
+	t.resetRoot()
+	for child := range built.root.children.all() {
+		setNodeParent(child, t.Root())
+		t.root.addChildren(child)
+	}
+
+	t.root.meta = built.root.meta
+	t.notify(append(events, t.rootEvents(EventCreate)...))
+	return nil
+}
+
+/*
+Writes the tree to w as an indented JSON document.
+*/
+func (t *Tree) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
+
+/*
+Reads a tree from a JSON document written by Save (or MarshalJSON).
+*/
+func Load(r io.Reader) (*Tree, error) {
+	t := CreateTree()
+	if err := json.NewDecoder(r).Decode(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
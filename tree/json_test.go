@@ -0,0 +1,62 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	original := CreateTree()
+	original.CreateFolder("/a", "b", true)
+	original.CreateFolder("/", "empty", false)
+	original.WriteFile("/a/b/f.txt", []byte("hello\x00world"))
+	original.CreateFile("/a", "e")
+
+	var buf bytes.Buffer
+	if err := original.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := loaded.ReadFile("/a/b/f.txt")
+	if err != nil || string(content) != "hello\x00world" {
+		t.Fatalf("unexpected content %q (%v)", content, err)
+	}
+
+	for _, p := range []string{"/a/e", "/empty"} {
+		if _, err := loaded.FollowPath(p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+
+	node, _ := loaded.FollowPath("/a/b")
+	if node.Parent().Parent() != loaded.Root() {
+		t.Fatal("parent pointers do not lead to the loaded root")
+	}
+}
+
+func TestJSONRejectsInvalidDocuments(t *testing.T) {
+	docs := []string{
+		`{"version":2,"root":{"type":"folder"}}`,
+		`{"version":1,"root":{"type":"file"}}`,
+		`{"version":1,"root":{"type":"folder","children":[{"type":"link","name":"x"}]}}`,
+		`{"version":1,"root":{"type":"folder","children":[{"type":"file","name":"x"},{"type":"folder","name":"x"}]}}`,
+		`{"version":1,"root":{"type":"folder","children":[{"type":"file","name":"a/b"}]}}`,
+	}
+
+	for _, doc := range docs {
+		tree := CreateTree()
+		tree.CreateFile("/", "kept")
+		if err := tree.UnmarshalJSON([]byte(doc)); err == nil {
+			t.Fatalf("document accepted: %s", doc)
+		}
+
+		if _, err := tree.FollowPath("/kept"); err != nil {
+			t.Fatalf("tree changed by a rejected document: %s", doc)
+		}
+	}
+}
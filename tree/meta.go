@@ -0,0 +1,326 @@
+package tree
+
+import (
+	"io/fs"
+	"maps"
+	"time"
+)
+
+/*
+Clock is the source of every timestamp stored in a tree. Trees use SystemClock unless another one is given to CreateTreeWithClock, which allows
+tests to control time.
+*/
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+/*
+The clock that reads the system time.
+*/
+var SystemClock Clock = systemClock{}
+
+/*
+Metadata shared by every kind of node.
+- modTime changes when the content changes (the data of a file or the children of a folder).
+- changeTime changes when the content or the metadata itself changes (e.g. a rename or a new attribute).
+- accessTime changes when the content is read.
+- mode holds the permission bits only, the type bits are given by the kind of node.
+*/
+type nodeMeta struct {
+	modTime    time.Time
+	changeTime time.Time
+	accessTime time.Time
+	attrs      map[string]string
+	mode       fs.FileMode
+	owner      string
+	group      string
+}
+
+func newNodeMeta(now time.Time, owner string, group string, mode fs.FileMode) nodeMeta {
+	return nodeMeta{
+		modTime:    now,
+		changeTime: now,
+		accessTime: now,
+		attrs:      map[string]string{},
+		mode:       mode & fs.ModePerm,
+		owner:      owner,
+		group:      group,
+	}
+}
+
+func (m *nodeMeta) modified(now time.Time) {
+	m.modTime = now
+	m.changeTime = now
+}
+
+func (m *nodeMeta) changed(now time.Time) {
+	m.changeTime = now
+}
+
+func (m *nodeMeta) accessed(now time.Time) {
+	m.accessTime = now
+}
+
+/*
+Returns a copy of the metadata that shares nothing with m.
+*/
+func (m *nodeMeta) clone() nodeMeta {
+	c := *m
+	c.attrs = maps.Clone(m.attrs)
+	return c
+}
+
+/*
+Returns the metadata of a node.
+*/
+func metaOf(n Node) *nodeMeta {
+	switch node := n.(type) {
+	case *FolderNode:
+		return &node.meta
+	case *FileNode:
+		return &node.meta
+	case *SymlinkNode:
+		return &node.meta
+	}
+
+	panic("unknown node type")
+}
+
+/*
+Sets every timestamp of n and its descendants to now and gives them to user, as happens to the nodes created by a copy. Attributes and
+permission bits are kept.
+*/
+func restamp(n Node, now time.Time, user User) {
+	meta := metaOf(n)
+	meta.modTime, meta.changeTime, meta.accessTime = now, now, now
+	meta.owner, meta.group = user.Name, user.primaryGroup()
+
+	if folder, ok := n.(*FolderNode); ok {
+		for child := range folder.children.all() {
+			restamp(child, now, user)
+		}
+	}
+}
+
+/*
+Returns the tree the folder belongs to, found through the root, or nil for folders outside of a tree.
+*/
+func (fn *FolderNode) owner() *Tree {
+	root := fn
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	return root.tree
+}
+
+/*
+Returns the current time according to the clock of the tree.
+*/
+func (t *Tree) now() time.Time {
+	if t.clock == nil {
+		return SystemClock.Now()
+	}
+
+	return t.clock.Now()
+}
+
+/*
+Returns the current time according to the clock of the tree the folder belongs to.
+*/
+func (fn *FolderNode) now() time.Time {
+	if t := fn.owner(); t != nil {
+		return t.now()
+	}
+
+	return SystemClock.Now()
+}
+
+/*
+Returns the current time according to the clock of the tree the file belongs to.
+*/
+func (fn *FileNode) now() time.Time {
+	return fn.parent.now()
+}
+
+/*
+NodeInfo describes a node at the moment Stat was called. It implements fs.FileInfo and adds the change and access times, the node attributes,
+ownership and inode details.
+The size of a file is the amount of bytes in it and the size of a folder is the amount of children it has.
+*/
+type NodeInfo struct {
+	name       string
+	size       int64
+	mode       fs.FileMode
+	modTime    time.Time
+	changeTime time.Time
+	accessTime time.Time
+	attrs      map[string]string
+	owner      string
+	group      string
+	inode      *inode
+	ino        uint64
+	nlink      int
+}
+
+func newNodeInfo(n Node, size int64, mode fs.FileMode) *NodeInfo {
+	meta := metaOf(n)
+	name := n.CleanName()
+	if n.Parent() == nil {
+		name = "/"
+	}
+
+	return &NodeInfo{
+		name:       name,
+		size:       size,
+		mode:       mode,
+		modTime:    meta.modTime,
+		changeTime: meta.changeTime,
+		accessTime: meta.accessTime,
+		attrs:      maps.Clone(meta.attrs),
+		owner:      meta.owner,
+		group:      meta.group,
+		inode:      inodeOf(n),
+		ino:        inodeOf(n).ino,
+		nlink:      inodeOf(n).nlink,
+	}
+}
+
+/*
+Returns a copy of the info with another name, used when a node is reached through an io/fs path.
+*/
+func (ni *NodeInfo) renamed(name string) *NodeInfo {
+	c := *ni
+	c.name = name
+	return &c
+}
+
+func (ni *NodeInfo) Name() string          { return ni.name }
+func (ni *NodeInfo) Size() int64           { return ni.size }
+func (ni *NodeInfo) Mode() fs.FileMode     { return ni.mode }
+func (ni *NodeInfo) ModTime() time.Time    { return ni.modTime }
+func (ni *NodeInfo) ChangeTime() time.Time { return ni.changeTime }
+func (ni *NodeInfo) AccessTime() time.Time { return ni.accessTime }
+func (ni *NodeInfo) IsDir() bool           { return ni.mode.IsDir() }
+func (ni *NodeInfo) Sys() any              { return nil }
+func (ni *NodeInfo) Owner() string         { return ni.owner }
+func (ni *NodeInfo) Group() string         { return ni.group }
+func (ni *NodeInfo) Ino() uint64           { return ni.ino }
+func (ni *NodeInfo) Nlink() int            { return ni.nlink }
+
+/*
+Returns a copy of the node attributes.
+*/
+func (ni *NodeInfo) Attrs() map[string]string {
+	return maps.Clone(ni.attrs)
+}
+
+/*
+Sets the attribute key of the node at path to value.
+*/
+func (t *Tree) SetAttr(path string, key string, value string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "set attribute %s of %s", key, path)()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return err
+	}
+
+	if key == "" {
+		return ETIInvalidAttribute
+	}
+
+	if err := t.checkAccess(node, accessWrite); err != nil {
+		return err
+	}
+
+	t.touch(node, false)
+	meta := metaOf(node)
+	meta.attrs[key] = value
+	meta.changed(t.now())
+	return nil
+}
+
+/*
+Removes the attribute key from the node at path. Removing an attribute that is not set is not an error.
+*/
+func (t *Tree) RemoveAttr(path string, key string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "remove attribute %s of %s", key, path)()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkAccess(node, accessWrite); err != nil {
+		return err
+	}
+
+	t.touch(node, false)
+	meta := metaOf(node)
+	if _, ok := meta.attrs[key]; ok {
+		delete(meta.attrs, key)
+		meta.changed(t.now())
+	}
+
+	return nil
+}
+
+/*
+Creates an empty file at path or, if it already exists, sets its access and modification times to now (as the touch command does).
+*/
+func (t *Tree) Touch(path string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "touch %s", path)()
+
+	node, err := t.follow(path)
+	if err != nil {
+		_, err = t.createFileAt(path)
+		return err
+	}
+
+	if err := t.checkAccess(node, accessWrite); err != nil {
+		return err
+	}
+
+	t.touch(node, false)
+	now := t.now()
+	meta := metaOf(node)
+	meta.modified(now)
+	meta.accessed(now)
+	return nil
+}
+
+/*
+Sets the access and modification times of the node at path, as os.Chtimes does. The change time becomes now.
+*/
+func (t *Tree) Chtimes(path string, atime time.Time, mtime time.Time) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "change the times of %s", path)()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkOwner(node); err != nil {
+		return err
+	}
+
+	t.touch(node, false)
+	meta := metaOf(node)
+	meta.accessTime = atime
+	meta.modTime = mtime
+	meta.changed(t.now())
+	return nil
+}
@@ -0,0 +1,110 @@
+package tree
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+/*
+A clock that only moves when told to.
+*/
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.current }
+
+func (c *fakeClock) advance() time.Time {
+	c.current = c.current.Add(time.Minute)
+	return c.current
+}
+
+func TestTimestamps(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tree := CreateTreeWithClock(clock)
+	created := clock.advance()
+	tree.CreateFolder("/", "docs", false)
+	tree.CreateFile("/docs", "a.txt")
+
+	info := mustFollow(t, tree, "/docs/a.txt").Stat()
+	if !info.ModTime().Equal(created) || !info.AccessTime().Equal(created) || !info.ChangeTime().Equal(created) {
+		t.Fatalf("unexpected creation times %v %v %v", info.ModTime(), info.AccessTime(), info.ChangeTime())
+	}
+
+	written := clock.advance()
+	tree.WriteFile("/docs/a.txt", []byte("hello"))
+	info = mustFollow(t, tree, "/docs/a.txt").Stat()
+	if !info.ModTime().Equal(written) || !info.AccessTime().Equal(created) || info.Size() != 5 {
+		t.Fatalf("write did not update the modification time only: %v %v", info.ModTime(), info.AccessTime())
+	}
+
+	read := clock.advance()
+	tree.ReadFile("/docs/a.txt")
+	if info = mustFollow(t, tree, "/docs/a.txt").Stat(); !info.AccessTime().Equal(read) || !info.ModTime().Equal(written) {
+		t.Fatalf("read did not update the access time only: %v %v", info.ModTime(), info.AccessTime())
+	}
+
+	renamed := clock.advance()
+	tree.Rename("/docs/a.txt", "b.txt")
+	if info = mustFollow(t, tree, "/docs/b.txt").Stat(); !info.ChangeTime().Equal(renamed) || !info.ModTime().Equal(written) {
+		t.Fatalf("rename did not update the change time only: %v %v", info.ModTime(), info.ChangeTime())
+	}
+
+	if info = mustFollow(t, tree, "/docs").Stat(); !info.ModTime().Equal(written.Add(-time.Minute)) || info.Size() != 1 || !info.IsDir() {
+		t.Fatalf("unexpected folder info %v %d", info.ModTime(), info.Size())
+	}
+
+	added := clock.advance()
+	tree.CreateFile("/docs", "c.txt")
+	if info = mustFollow(t, tree, "/docs").Stat(); !info.ModTime().Equal(added) || info.Size() != 2 {
+		t.Fatalf("adding a child did not update the folder: %v %d", info.ModTime(), info.Size())
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tree := CreateTreeWithClock(clock)
+	tree.CreateFile("/", "a.txt")
+
+	if err := tree.SetAttr("/a.txt", "", "x"); err != ETIInvalidAttribute {
+		t.Fatalf("expected ETIInvalidAttribute, got %v", err)
+	}
+
+	changed := clock.advance()
+	tree.SetAttr("/a.txt", "owner", "team")
+	info := mustFollow(t, tree, "/a.txt").Stat()
+	if info.Attrs()["owner"] != "team" || !info.ChangeTime().Equal(changed) {
+		t.Fatalf("unexpected attributes %v (%v)", info.Attrs(), info.ChangeTime())
+	}
+
+	info.Attrs()["owner"] = "changed"
+	if mustFollow(t, tree, "/a.txt").Stat().Attrs()["owner"] != "team" {
+		t.Fatal("attributes returned by Stat should be copies")
+	}
+
+	var buf bytes.Buffer
+	tree.Save(&buf)
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info := mustFollow(t, loaded, "/a.txt").Stat(); info.Attrs()["owner"] != "team" || !info.ChangeTime().Equal(changed) {
+		t.Fatalf("metadata lost by JSON: %v %v", info.Attrs(), info.ChangeTime())
+	}
+
+	tree.RemoveAttr("/a.txt", "owner")
+	if len(mustFollow(t, tree, "/a.txt").Stat().Attrs()) != 0 {
+		t.Fatal("attribute not removed")
+	}
+}
+
+func mustFollow(t *testing.T, tree *Tree, path string) Node {
+	t.Helper()
+	node, err := tree.FollowPath(path)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return node
+}
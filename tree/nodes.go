@@ -2,6 +2,8 @@ package tree
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"strings"
 )
 
@@ -13,6 +15,7 @@ This interface also includes the implementation of the fmt.Stringer interface, w
 type Node interface {
 	IsFile() bool
 	IsFolder() bool
+	IsSymlink() bool
 
 	Name() string
 	/*
@@ -24,6 +27,12 @@ type Node interface {
 
 	AsFile() (*FileNode, error)
 	AsFolder() (*FolderNode, error)
+	AsSymlink() (*SymlinkNode, error)
+
+	// Returns the size, timestamps and attributes of the node.
+	Stat() *NodeInfo
+	// Returns the Merkle hash of the node and everything inside it.
+	Hash() Hash
 
 	fmt.Stringer
 }
@@ -35,56 +44,68 @@ It also defines that a folder can father any kind of Node (Folder or File), but
 type FolderNode struct {
 	name     string
 	parent   *FolderNode
-	children []Node
+	children childList
+	*inode
+	tree *Tree // only set on the root, see owner()
 }
 
 /*
 FileNode is a concrete implementation of the Node interface that virtually represents a file. Files are a special branch of nodes because they are always leaf nodes (i.e can't have children).
+A file holds its data as a byte payload, which is empty (but never required to be non-nil) right after creation.
 */
 type FileNode struct {
 	name   string
 	parent *FolderNode
+	*inode // holds the content, shared with the other hard links to the file
 }
 
 // Node interface implementation for FolderNode
-func (fn *FolderNode) IsFile() bool                   { return false }
-func (fn *FolderNode) IsFolder() bool                 { return true }
-func (fn *FolderNode) Name() string                   { return fn.name }
-func (fn *FolderNode) Parent() *FolderNode            { return fn.parent }
-func (fn *FolderNode) AsFile() (*FileNode, error)     { return nil, ETIFolderAsFile }
-func (fn *FolderNode) AsFolder() (*FolderNode, error) { return fn, nil }
-func (fn *FolderNode) CleanName() string              { return fn.Name()[:len(fn.Name())-1] }
+func (fn *FolderNode) IsFile() bool                     { return false }
+func (fn *FolderNode) IsFolder() bool                   { return true }
+func (fn *FolderNode) IsSymlink() bool                  { return false }
+func (fn *FolderNode) Name() string                     { return fn.name }
+func (fn *FolderNode) Parent() *FolderNode              { return fn.parent }
+func (fn *FolderNode) AsFile() (*FileNode, error)       { return nil, ETIFolderAsFile }
+func (fn *FolderNode) AsFolder() (*FolderNode, error)   { return fn, nil }
+func (fn *FolderNode) AsSymlink() (*SymlinkNode, error) { return nil, ETINotASymlink }
+func (fn *FolderNode) CleanName() string                { return fn.Name()[:len(fn.Name())-1] }
+func (fn *FolderNode) Stat() *NodeInfo {
+	return newNodeInfo(fn, int64(fn.children.len()), fs.ModeDir|fn.meta.mode)
+}
 
 // Stringer interface implementation for FolderNode
 func (fn *FolderNode) String() string {
 	var childlist string = ""
-	for _, c := range fn.children {
+	for c := range fn.children.all() {
 		childlist = fmt.Sprintf("%s\n%s", childlist, c)
 	}
 
-	var str string = fmt.Sprintf("[Name: %s\nChild Count: %d\n\tChildList: %s]", fn.name, len(fn.children), childlist)
+	var str string = fmt.Sprintf("[Name: %s\nChild Count: %d\n\tChildList: %s]", fn.name, fn.children.len(), childlist)
 	return str
 }
 
 // Node interface implementation for FileNode
-func (fn *FileNode) IsFile() bool                   { return true }
-func (fn *FileNode) IsFolder() bool                 { return false }
-func (fn *FileNode) Name() string                   { return fn.name }
-func (fn *FileNode) Parent() *FolderNode            { return fn.parent }
-func (fn *FileNode) AsFile() (*FileNode, error)     { return fn, nil }
-func (fn *FileNode) AsFolder() (*FolderNode, error) { return nil, ETIFileAsFolder }
-func (fn *FileNode) CleanName() string              { return fn.Name() }
+func (fn *FileNode) IsFile() bool                     { return true }
+func (fn *FileNode) IsFolder() bool                   { return false }
+func (fn *FileNode) IsSymlink() bool                  { return false }
+func (fn *FileNode) Name() string                     { return fn.name }
+func (fn *FileNode) Parent() *FolderNode              { return fn.parent }
+func (fn *FileNode) AsFile() (*FileNode, error)       { return fn, nil }
+func (fn *FileNode) AsFolder() (*FolderNode, error)   { return nil, ETIFileAsFolder }
+func (fn *FileNode) AsSymlink() (*SymlinkNode, error) { return nil, ETINotASymlink }
+func (fn *FileNode) CleanName() string                { return fn.Name() }
+func (fn *FileNode) Stat() *NodeInfo                  { return newNodeInfo(fn, int64(fn.Size()), fn.meta.mode) }
 
 // Stringer interface implementation for FileNode
 func (fn *FileNode) String() string {
-	var str string = fmt.Sprintf("=FILE=\nName: %s\nParent:%s\n==", fn.Name(), fn.Parent().Name())
+	var str string = fmt.Sprintf("=FILE=\nName: %s\nParent:%s\nSize: %d\n==", fn.Name(), fn.Parent().Name(), fn.Size())
 	return str
 }
 
 // General functions
 func ValidateNodeName(name string) error {
 	// Cannot check for starts with . bc there are valid hidden folders that start with .
-	if strings.ContainsRune(name, '\\') || strings.ContainsRune(name, '/') || name == "." || name == ".." {
+	if name == "" || strings.ContainsRune(name, '\\') || strings.ContainsRune(name, '/') || name == "." || name == ".." {
 		return ETINameNotValid
 	}
 
@@ -101,7 +122,140 @@ func ValidateNodeName(name string) error {
 Inserts a new child node into children field.
 */
 func (fn *FolderNode) addChildren(n Node) {
-	fn.children = append(fn.children, n)
+	t := fn.owner()
+	t.touch(fn, false)
+	if t != nil && t.inodes[inodeOf(n).ino] != inodeOf(n) {
+		t.touchAll(n)
+	}
+	t.recordChange(placement{node: n, parent: fn, name: n.CleanName(), attached: true})
+
+	fn.children.insert(n, nil)
+	fn.meta.modified(fn.now())
+	if t != nil {
+		t.register(n)
+	}
+}
+
+/*
+Changes the name of a node in place. Folder names receive the trailing bar, as done by NewFolderNode. The name must be validated by the caller.
+*/
+func setNodeName(n Node, name string) {
+	if t := ownerOf(n); t != nil && t.inodes[inodeOf(n).ino] == inodeOf(n) {
+		t.touch(n, false)
+		t.recordChange(renaming{node: n, from: n.CleanName(), to: name})
+	}
+
+	if parent := n.Parent(); parent != nil && parent.children.contains(n) {
+		parent.children.rename(n.CleanName(), name)
+	}
+
+	switch node := n.(type) {
+	case *FolderNode:
+		node.name = name + "/"
+		node.meta.changed(node.now())
+	case *FileNode:
+		node.name = name
+		node.meta.changed(node.now())
+	case *SymlinkNode:
+		node.name = name
+		node.meta.changed(node.parent.now())
+	}
+}
+
+/*
+Changes the parent pointer of a node. It does not touch the children of either parent.
+*/
+func setNodeParent(n Node, parent *FolderNode) {
+	switch node := n.(type) {
+	case *FolderNode:
+		node.parent = parent
+	case *FileNode:
+		node.parent = parent
+	case *SymlinkNode:
+		node.parent = parent
+	}
+}
+
+/*
+Returns true if fn is folder or one of its descendants.
+*/
+func (fn *FolderNode) isWithin(folder *FolderNode) bool {
+	for current := fn; current != nil; current = current.Parent() {
+		if current == folder {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Detaches n from its current parent and attaches it to fn under the given name, fixing the parent pointer. The name must be validated by the caller.
+*/
+func (fn *FolderNode) adopt(n Node, name string) error {
+	if oldParent := n.Parent(); oldParent != nil {
+		if _, err := oldParent.detach(n.CleanName()); err != nil {
+			return err
+		}
+	}
+
+	setNodeName(n, name)
+	setNodeParent(n, fn)
+	fn.addChildren(n)
+	return nil
+}
+
+/*
+Maps the inodes of copied nodes to their copies, so that hard links between copied nodes are copied as hard links.
+*/
+type inodeCopies map[*inode]*inode
+
+/*
+Returns the copy of in, made on the first call, counting one more link to it.
+*/
+func (c inodeCopies) get(in *inode) *inode {
+	copied, ok := c[in]
+	if !ok {
+		copied = in.clone()
+		c[in] = copied
+	}
+
+	copied.nlink++
+	return copied
+}
+
+/*
+Returns a deep copy of n attached to parent. Folders are copied along with all their descendants, whose parent pointers point to the copies.
+Hard links between the copied files are kept, using inodes to find them. The copy is not added to parent's children, that is up to the caller.
+*/
+func cloneNode(n Node, parent *FolderNode, inodes inodeCopies) Node {
+	switch node := n.(type) {
+	case *FolderNode:
+		return node.cloneInto(parent, inodes)
+	case *FileNode:
+		return &FileNode{name: node.name, parent: parent, inode: inodes.get(node.inode)}
+	case *SymlinkNode:
+		return &SymlinkNode{name: node.name, parent: parent, target: node.target, inode: inodes.get(node.inode)}
+	}
+
+	panic("unknown node type")
+}
+
+/*
+Returns a deep copy of fn attached to parent (see cloneNode).
+*/
+func (fn *FolderNode) cloneInto(parent *FolderNode, inodes inodeCopies) *FolderNode {
+	clone := &FolderNode{
+		name:   fn.name,
+		parent: parent,
+		inode:  inodes.get(fn.inode),
+	}
+
+	for child := range fn.children.all() {
+		clone.children.insert(cloneNode(child, clone, inodes), nil)
+	}
+
+	return clone
 }
 
 /*
@@ -109,9 +263,9 @@ Creates a special root folder.
 */
 func createRootFolder() *FolderNode {
 	return &FolderNode{
-		name:     "./",
-		parent:   nil,
-		children: []Node{},
+		name:   "./",
+		parent: nil,
+		inode:  newInode(newNodeMeta(SystemClock.Now(), RootUser.Name, RootUser.primaryGroup(), 0o755)),
 	}
 }
 
@@ -120,7 +274,7 @@ func createRootFolder() *FolderNode {
 // Constructor
 
 /*
-Builds a new folder node with a parent and without children. By design the children slice is always non-null (but can be empty)
+Builds a new folder node with a parent and without children.
 */
 func NewFolderNode(name string, parent *FolderNode) (*FolderNode, error) {
 	if err := ValidateNodeName(name); err != nil {
@@ -128,32 +282,12 @@ func NewFolderNode(name string, parent *FolderNode) (*FolderNode, error) {
 	}
 
 	return &FolderNode{
-		name:     name + "/",
-		parent:   parent,
-		children: []Node{},
+		name:   name + "/",
+		parent: parent,
+		inode:  newInode(childMeta(parent, 0o777)),
 	}, nil
 }
 
-func (fn *FolderNode) GraphVizOutput() string {
-	graphviz := ""
-	for _, c := range fn.children {
-		clnName := fn.CleanName()
-		if clnName == "." {
-			clnName = "/"
-		}
-		graphviz = graphviz + "\"" + clnName + "\" -> \"" + c.CleanName() + "\"" + "\n"
-		if c.IsFolder() {
-			cAF, err := c.AsFolder()
-			if err != nil {
-				panic("should not have a non nil error at this point")
-			}
-			graphviz = graphviz + cAF.GraphVizOutput()
-		}
-	}
-
-	return graphviz
-}
-
 /*
 Checks if there's a specific child. Returns it if it exists or an error if it doesn't.
 */
@@ -162,20 +296,20 @@ func (fn *FolderNode) SearchChild(name string) (Node, error) {
 		return nil, ETINoChildren
 	}
 
-	foldersChildren, err := fn.GetChildren()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, child := range foldersChildren {
-		if child.Name() == name {
-			return child, nil
-		}
+	if child := fn.childNamed(strings.TrimSuffix(name, "/")); child != nil && child.Name() == name {
+		return child, nil
 	}
 
 	return nil, ETIChildNotFound
 }
 
+/*
+Returns the child whose clean name is name, or nil if there is none. Unlike SearchChild, folders and files are matched by the same (clean) name.
+*/
+func (fn *FolderNode) childNamed(name string) Node {
+	return fn.children.get(name)
+}
+
 /*
 Returns true if the current node has a parent, false otherwise.
 */
@@ -187,21 +321,15 @@ func (fn *FolderNode) HasParent() bool {
 Returns true if the current node has at least one children, false otherwise.
 */
 func (fn *FolderNode) HasChildren() bool {
-	return (len(fn.children) > 0)
+	return (fn.children.len() > 0)
 }
 
 /*
-Returns a copy of the children slice of the current node.
+Returns the children of the current node, in insertion order, in a new slice.
 It has an error handling armor but at the moment it cannot possibly fail.
-
-One edge case happens if the folder's children field is NIL. It isn't supposed to be, so it will trigger a panic.
 */
 func (fn *FolderNode) GetChildren() ([]Node, error) {
-	if fn.children == nil {
-		panic("something is not right, children shouldn't be nil")
-	}
-
-	return append([]Node(nil), fn.children...), nil
+	return fn.children.slice(), nil
 }
 
 /*
@@ -210,7 +338,7 @@ Returns a slice containing all children that are of Folder type. It will fail wi
 func (fn *FolderNode) GetFolderChildren() ([]FolderNode, error) {
 	var resp []FolderNode = make([]FolderNode, 0)
 
-	for _, item := range fn.children {
+	for item := range fn.children.all() {
 		folder, err := item.AsFolder()
 		if err == nil {
 			resp = append(resp, *folder)
@@ -230,7 +358,7 @@ Returns a slice containing all children that are of File type.  It will fail wit
 func (fn *FolderNode) GetFileChildren() ([]FileNode, error) {
 	var resp []FileNode = make([]FileNode, 0)
 
-	for _, item := range fn.children {
+	for item := range fn.children.all() {
 		file, err := item.AsFile()
 		if err == nil {
 			resp = append(resp, *file)
@@ -244,31 +372,45 @@ func (fn *FolderNode) GetFileChildren() ([]FileNode, error) {
 	return resp, nil
 }
 
+/*
+Removes the child named name, dropping the links it (and everything inside it) holds on their inodes. The content of a file is freed once its last
+link is removed and no File has it open.
+*/
 func (fn *FolderNode) RemoveNode(name string) error {
-	itemPos := -1
-	for i, n := range fn.children {
-		if n.CleanName() == name {
-			itemPos = i
-			break
-		}
+	removed, err := fn.detach(name)
+	if err != nil {
+		return err
 	}
 
-	if itemPos == -1 {
-		return ETIChildNotFound
+	unlinkNode(removed, fn.owner())
+	return nil
+}
+
+/*
+Takes the child named name out of the children, leaving its inode untouched (as a move does).
+*/
+func (fn *FolderNode) detach(name string) (Node, error) {
+	removed := fn.childNamed(name)
+	if removed == nil {
+		return nil, ETIChildNotFound
 	}
 
-	fn.children = append(fn.children[:itemPos], fn.children[itemPos+1:]...)
-	return nil
+	t := fn.owner()
+	t.touch(fn, false)
+	t.touch(removed, false)
+
+	next, _ := fn.children.remove(removed)
+	t.recordChange(placement{node: removed, parent: fn, next: next, name: name, attached: false})
+	fn.meta.modified(fn.now())
+	return removed, nil
 }
 
 /*
 Adds a new Folder as children of the current folder.
 */
 func (fn *FolderNode) InsertFolder(name string) (*FolderNode, error) {
-	for _, child := range fn.children {
-		if child.CleanName() == name {
-			return nil, ETIDuplicatedName
-		}
+	if fn.childNamed(name) != nil {
+		return nil, ETIDuplicatedName
 	}
 	newFolder, err := NewFolderNode(name, fn)
 	if err != nil {
@@ -284,10 +426,8 @@ func (fn *FolderNode) InsertFolder(name string) (*FolderNode, error) {
 Adds a new File as children of the current folder.
 */
 func (fn *FolderNode) InsertFile(name string) (*FileNode, error) {
-	for _, child := range fn.children {
-		if child.CleanName() == name {
-			return nil, ETIDuplicatedName
-		}
+	if fn.childNamed(name) != nil {
+		return nil, ETIDuplicatedName
 	}
 
 	newFile, err := NewFileNode(name, fn)
@@ -307,7 +447,7 @@ Performs a depth first search on the current node's children.
 func (fn *FolderNode) DFS(name string) ([]Node, error) {
 	var results []Node
 
-	for _, c := range fn.children {
+	for c := range fn.children.all() {
 		if c.CleanName() == name {
 			results = append(results, c)
 		}
@@ -338,6 +478,109 @@ func (fn *FolderNode) DFS(name string) ([]Node, error) {
 
 /* PUBLISHED */
 
+/*
+Returns the amount of bytes stored in the file.
+*/
+func (fn *FileNode) Size() int {
+	return len(fn.content)
+}
+
+/*
+Returns a copy of the file's content. Changing the returned slice does not change the file.
+*/
+func (fn *FileNode) Content() []byte {
+	return append([]byte(nil), fn.content...)
+}
+
+/*
+Replaces the whole content of the file with a copy of data.
+*/
+func (fn *FileNode) Write(data []byte) {
+	ownerOf(fn).touch(fn, true)
+	fn.content = append([]byte(nil), data...)
+	fn.meta.modified(fn.now())
+}
+
+/*
+Appends a copy of data to the end of the file's content.
+*/
+func (fn *FileNode) Append(data []byte) {
+	ownerOf(fn).touch(fn, true)
+	fn.unshare()
+	fn.content = append(fn.content, data...)
+	fn.meta.modified(fn.now())
+}
+
+/*
+Copies the content starting at off into p, returning io.EOF if p could not be filled.
+*/
+func (fn *FileNode) readAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(fn.content)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, fn.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+/*
+The largest size a file can be grown to by writing past its end or truncating it, as the gap is allocated at once. Offsets past it are not valid.
+*/
+const MaxFileSize = 1 << 30
+
+/*
+Writes p at off, growing the file if needed. The gap between the old end of the file and off (if any) is zeroed. ETIFileTooLarge is returned,
+and nothing is written, if the file would end past MaxFileSize.
+*/
+func (fn *FileNode) writeAt(p []byte, off int64) error {
+	if off > MaxFileSize || int64(len(p)) > MaxFileSize-off {
+		return ETIFileTooLarge
+	}
+
+	ownerOf(fn).touch(fn, true)
+	fn.unshare()
+	end := int(off) + len(p)
+	if end > len(fn.content) {
+		fn.content = append(fn.content, make([]byte, end-len(fn.content))...)
+	}
+
+	copy(fn.content[off:], p)
+	fn.meta.modified(fn.now())
+	return nil
+}
+
+/*
+Changes the size of the file. If the file is shrunk the extra data is discarded, if it grows the new bytes are zeroed (same as os.Truncate). The
+size cannot be over MaxFileSize.
+*/
+func (fn *FileNode) Truncate(size int) error {
+	if size < 0 {
+		return ETINegativeSize
+	}
+
+	if size > MaxFileSize {
+		return ETIFileTooLarge
+	}
+
+	ownerOf(fn).touch(fn, true)
+	fn.unshare()
+	if size <= len(fn.content) {
+		fn.content = fn.content[:size:size]
+	} else {
+		fn.content = append(fn.content, make([]byte, size-len(fn.content))...)
+	}
+
+	fn.meta.modified(fn.now())
+	return nil
+}
+
 // Constructor
 
 func NewFileNode(name string, parent *FolderNode) (*FileNode, error) {
@@ -352,6 +595,7 @@ func NewFileNode(name string, parent *FolderNode) (*FileNode, error) {
 	return &FileNode{
 		name:   name,
 		parent: parent,
+		inode:  newInode(childMeta(parent, 0o666)),
 	}, nil
 }
 
@@ -361,7 +605,9 @@ func StructuredPrint(n Node, il int) {
 		istr = istr + " "
 	}
 
-	if n.IsFile() {
+	if link, ok := n.(*SymlinkNode); ok {
+		fmt.Println(istr + link.Name() + " -> " + link.Target())
+	} else if n.IsFile() {
 		fmt.Println(istr + n.Name())
 	} else {
 		fmt.Println(istr + n.Name())
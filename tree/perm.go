@@ -0,0 +1,274 @@
+package tree
+
+import (
+	"io/fs"
+	"slices"
+)
+
+/*
+User is the identity the operations on a tree are performed as. The first group is the primary group, given to the nodes the user creates.
+
+Every Tree method that takes a path checks the Unix permission bits of the nodes it touches:
+- traversing a folder (i.e. looking up one of its children) needs execute permission on it.
+- listing a folder or reading a file needs read permission on it.
+- writing to a file (or changing its attributes and times) needs write permission on it.
+- creating, removing, moving or renaming a node needs write and execute permission on the folder holding it. Removing a folder recursively also
+needs read, write and execute permission on every folder inside it.
+- only the owner of a node can change its mode and only the root user can give it away (see Chmod and Chown).
+
+The root user is allowed to do everything. The node level methods (FolderNode.InsertFile and the like) and the whole tree operations (Clone,
+Save, GraphViz, WriteToDir...) are not checked.
+*/
+type User struct {
+	Name   string
+	Groups []string
+}
+
+/*
+The superuser. Trees start with it as their user, so permissions are not enforced unless SetUser is called.
+*/
+var RootUser = User{Name: "root", Groups: []string{"root"}}
+
+/*
+The umask trees start with.
+*/
+const DefaultUmask fs.FileMode = 0o022
+
+const (
+	accessRead  fs.FileMode = 0o4
+	accessWrite fs.FileMode = 0o2
+	accessExec  fs.FileMode = 0o1
+)
+
+func (u User) isRoot() bool {
+	return u.Name == RootUser.Name
+}
+
+/*
+Returns the primary group of the user, or its name if it has no groups.
+*/
+func (u User) primaryGroup() string {
+	if len(u.Groups) == 0 {
+		return u.Name
+	}
+
+	return u.Groups[0]
+}
+
+func (u User) inGroup(group string) bool {
+	return slices.Contains(u.Groups, group)
+}
+
+/*
+Sets the user the next operations are performed as.
+*/
+func (t *Tree) SetUser(u User) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.user = u
+}
+
+/*
+Returns the user the operations are performed as.
+*/
+func (t *Tree) User() User {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.user
+}
+
+/*
+Sets the umask, whose permission bits are removed from the mode of every node created afterwards. The previous umask is returned.
+*/
+func (t *Tree) SetUmask(mask fs.FileMode) fs.FileMode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous := t.umask
+	t.umask = mask & fs.ModePerm
+	return previous
+}
+
+/*
+Returns the current umask.
+*/
+func (t *Tree) Umask() fs.FileMode {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.umask
+}
+
+/*
+Returns the metadata of a node created inside parent: it is owned by the user of the tree and its mode is base without the umask bits. Nodes
+created outside of a tree belong to root and use DefaultUmask.
+*/
+func childMeta(parent *FolderNode, base fs.FileMode) nodeMeta {
+	if parent == nil {
+		return newNodeMeta(SystemClock.Now(), RootUser.Name, RootUser.primaryGroup(), base&^DefaultUmask)
+	}
+
+	t := parent.owner()
+	if t == nil {
+		return newNodeMeta(parent.now(), RootUser.Name, RootUser.primaryGroup(), base&^DefaultUmask)
+	}
+
+	return newNodeMeta(t.now(), t.user.Name, t.user.primaryGroup(), base&^t.umask)
+}
+
+/*
+Returns nil if the current user has all the permissions in want (a combination of accessRead, accessWrite and accessExec) on n.
+*/
+func (t *Tree) checkAccess(n Node, want fs.FileMode) error {
+	if t.user.isRoot() {
+		return nil
+	}
+
+	meta := metaOf(n)
+	var granted fs.FileMode
+	switch {
+	case meta.owner == t.user.Name:
+		granted = meta.mode >> 6
+	case t.user.inGroup(meta.group):
+		granted = meta.mode >> 3
+	default:
+		granted = meta.mode
+	}
+
+	if granted&want != want {
+		return ETIPermissionDenied
+	}
+
+	return nil
+}
+
+/*
+Returns nil if the current user can add or remove children of folder.
+*/
+func (t *Tree) checkModifyFolder(folder *FolderNode) error {
+	return t.checkAccess(folder, accessWrite|accessExec)
+}
+
+/*
+Returns nil if the current user owns n (or is root).
+*/
+func (t *Tree) checkOwner(n Node) error {
+	if t.user.isRoot() || metaOf(n).owner == t.user.Name {
+		return nil
+	}
+
+	return ETIPermissionDenied
+}
+
+/*
+Returns nil if the current user can remove everything inside folder.
+*/
+func (t *Tree) checkRemoveAll(folder *FolderNode) error {
+	if !folder.HasChildren() {
+		return nil
+	}
+
+	if err := t.checkAccess(folder, accessRead|accessWrite|accessExec); err != nil {
+		return err
+	}
+
+	for child := range folder.children.all() {
+		if sub, ok := child.(*FolderNode); ok {
+			if err := t.checkRemoveAll(sub); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+Changes the permission bits of the node at path. Only the owner of the node (or root) can do it.
+*/
+func (t *Tree) Chmod(path string, mode fs.FileMode) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "change the mode of %s to %04o", path, uint32(mode))()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkOwner(node); err != nil {
+		return err
+	}
+
+	t.touch(node, false)
+	meta := metaOf(node)
+	meta.mode = mode & fs.ModePerm
+	meta.changed(t.now())
+	return nil
+}
+
+/*
+Changes the owner and/or the group of the node at path, an empty value leaves the respective field untouched. Only root can change the owner,
+while the owner of a node can change its group to one of the groups they belong to.
+*/
+func (t *Tree) Chown(path string, owner string, group string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "change the owner of %s", path)()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return err
+	}
+
+	meta := metaOf(node)
+	if !t.user.isRoot() {
+		if owner != "" && owner != meta.owner {
+			return ETIPermissionDenied
+		}
+
+		if err := t.checkOwner(node); err != nil {
+			return err
+		}
+
+		if group != "" && !t.user.inGroup(group) {
+			return ETIPermissionDenied
+		}
+	}
+
+	t.touch(node, false)
+	if owner != "" {
+		meta.owner = owner
+	}
+
+	if group != "" {
+		meta.group = group
+	}
+
+	meta.changed(t.now())
+	return nil
+}
+
+/*
+Returns nil if the current user can read n and, for folders, everything inside it (as a recursive copy does).
+*/
+func (t *Tree) checkReadAll(n Node) error {
+	folder, ok := n.(*FolderNode)
+	if !ok {
+		return t.checkAccess(n, accessRead)
+	}
+
+	if err := t.checkAccess(folder, accessRead|accessExec); err != nil {
+		return err
+	}
+
+	for child := range folder.children.all() {
+		if err := t.checkReadAll(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
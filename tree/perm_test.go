@@ -0,0 +1,176 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+var (
+	alice = User{Name: "alice", Groups: []string{"alice", "staff"}}
+	bob   = User{Name: "bob", Groups: []string{"bob", "staff"}}
+	eve   = User{Name: "eve", Groups: []string{"eve"}}
+)
+
+/*
+Builds a tree where /home/alice belongs to alice (0750, group staff) and holds notes.txt (0640, group staff).
+*/
+func permTree(t *testing.T) *Tree {
+	t.Helper()
+	tree := CreateTree()
+	tree.CreateFolder("/", "home", false)
+	tree.CreateFolder("/home", "alice", false)
+	tree.Chown("/home/alice", "alice", "staff")
+
+	tree.SetUser(alice)
+	if _, err := tree.CreateFile("/home/alice", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	tree.WriteFile("/home/alice/notes.txt", []byte("secret"))
+	tree.Chmod("/home/alice", 0o750)
+	tree.Chmod("/home/alice/notes.txt", 0o640)
+	tree.Chown("/home/alice/notes.txt", "", "staff")
+	return tree
+}
+
+func TestNewNodeModes(t *testing.T) {
+	tree := CreateTree()
+	tree.SetUser(alice)
+	tree.SetUmask(0o027)
+	tree.Chmod("/", 0o777) // not the owner
+
+	if info := tree.Root().Stat(); info.Mode() != fs.ModeDir|0o755 || info.Owner() != "root" || info.Group() != "root" {
+		t.Fatalf("unexpected root %v %s:%s", info.Mode(), info.Owner(), info.Group())
+	}
+
+	tree.SetUser(RootUser)
+	tree.Chmod("/", 0o777)
+	tree.SetUser(alice)
+	tree.CreateFolder("/", "dir", false)
+	tree.CreateFile("/dir", "file")
+
+	if info := mustFollow(t, tree, "/dir").Stat(); info.Mode() != fs.ModeDir|0o750 || info.Owner() != "alice" || info.Group() != "alice" {
+		t.Fatalf("unexpected folder %v %s:%s", info.Mode(), info.Owner(), info.Group())
+	}
+
+	if info := mustFollow(t, tree, "/dir/file").Stat(); info.Mode() != 0o640 {
+		t.Fatalf("unexpected file mode %v", info.Mode())
+	}
+
+	f, err := tree.OpenFile("/dir/private", os.O_WRONLY|os.O_CREATE, 0o400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("x")); err != nil {
+		t.Fatalf("the handle that created the file should be writable: %v", err)
+	}
+	f.Close()
+
+	if info := mustFollow(t, tree, "/dir/private").Stat(); info.Mode() != 0o400 {
+		t.Fatalf("unexpected mode %v for a file created by OpenFile", info.Mode())
+	}
+
+	if _, err := tree.OpenFile("/dir/private", os.O_WRONLY, 0); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected permission denied reopening a read-only file, got %v", err)
+	}
+}
+
+func TestPermissionChecks(t *testing.T) {
+	tree := permTree(t)
+
+	tree.SetUser(bob) // in staff: can read and traverse, can't write
+	if content, err := tree.ReadFile("/home/alice/notes.txt"); err != nil || !bytes.Equal(content, []byte("secret")) {
+		t.Fatalf("group member could not read: %q %v", content, err)
+	}
+
+	checks := map[string]error{
+		"write":       tree.WriteFile("/home/alice/notes.txt", []byte("x")),
+		"create":      errOf(tree.CreateFile("/home/alice", "bob.txt")),
+		"remove":      tree.RemoveFile("/home/alice/notes.txt"),
+		"rename":      tree.Rename("/home/alice/notes.txt", "mine.txt"),
+		"setattr":     tree.SetAttr("/home/alice/notes.txt", "k", "v"),
+		"chmod":       tree.Chmod("/home/alice/notes.txt", 0o777),
+		"chown":       tree.Chown("/home/alice/notes.txt", "bob", ""),
+		"remove root": tree.RemoveFolder("/home", true),
+	}
+	for op, err := range checks {
+		if !errors.Is(err, ETIPermissionDenied) || !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: expected permission denied, got %v", op, err)
+		}
+	}
+
+	tree.SetUser(eve) // others: nothing at all, not even traversal
+	if _, err := tree.FollowPath("/home/alice/notes.txt"); err != ETIPermissionDenied {
+		t.Fatalf("expected traversal to be denied, got %v", err)
+	}
+
+	if _, err := tree.ReadDir("/home/alice"); err != ETIPermissionDenied {
+		t.Fatalf("expected listing to be denied, got %v", err)
+	}
+
+	if _, err := fs.ReadFile(tree.FS(), "home/alice/notes.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected the io/fs read to be denied, got %v", err)
+	}
+
+	tree.SetUser(alice)
+	if err := tree.Chown("/home/alice/notes.txt", "", "alice"); err != nil {
+		t.Fatalf("owner could not change the group: %v", err)
+	}
+
+	if err := tree.Chown("/home/alice/notes.txt", "", "wheel"); err != ETIPermissionDenied {
+		t.Fatalf("owner gave the node to a foreign group: %v", err)
+	}
+
+	if err := tree.RemoveFolder("/home/alice", true); err != ETIPermissionDenied {
+		t.Fatalf("alice removed a folder from /home without write permission on it: %v", err)
+	}
+
+	tree.SetUser(RootUser)
+	if err := tree.RemoveFolder("/home", true); err != nil {
+		t.Fatalf("root could not remove: %v", err)
+	}
+}
+
+func TestCopyOwnershipAndJSON(t *testing.T) {
+	tree := permTree(t)
+	tree.SetUser(bob)
+	if _, err := tree.Copy("/home/alice/notes.txt", "/copy.txt", false); err != ETIPermissionDenied {
+		t.Fatalf("expected the copy into / to be denied, got %v", err)
+	}
+
+	tree.SetUser(RootUser)
+	tree.Chmod("/", 0o777)
+	tree.SetUser(bob)
+	if _, err := tree.Copy("/home/alice/notes.txt", "/copy.txt", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if info := mustFollow(t, tree, "/copy.txt").Stat(); info.Owner() != "bob" || info.Group() != "bob" || info.Mode() != 0o640 {
+		t.Fatalf("unexpected copy %v %s:%s", info.Mode(), info.Owner(), info.Group())
+	}
+
+	var buf bytes.Buffer
+	if err := tree.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := mustFollow(t, loaded, "/home/alice").Stat()
+	if info.Mode() != fs.ModeDir|0o750 || info.Owner() != "alice" || info.Group() != "staff" {
+		t.Fatalf("permissions lost in JSON: %v %s:%s", info.Mode(), info.Owner(), info.Group())
+	}
+
+	if info := loaded.Root().Stat(); info.Mode() != fs.ModeDir|0o777 {
+		t.Fatalf("root mode lost in JSON: %v", info.Mode())
+	}
+}
+
+func errOf[T any](_ T, err error) error {
+	return err
+}
@@ -0,0 +1,107 @@
+package tree
+
+import (
+	"slices"
+	"time"
+)
+
+/*
+SnapshotInfo describes a snapshot of a tree: the name it was given and when it was taken.
+*/
+type SnapshotInfo struct {
+	Name string
+	Time time.Time
+}
+
+/*
+A copy of the whole tree kept under a name. Copies share the content of the files until either side changes it, so taking a snapshot costs
+about as much as walking the tree.
+*/
+type snapshot struct {
+	SnapshotInfo
+	tree *Tree
+}
+
+/*
+Saves the current state of the tree as the snapshot name, replacing the snapshot that already had that name, if any. Snapshots keep the inode
+numbers of the nodes, so they can be compared with each other and with the tree (see Diff). The working copy of a transaction cannot take
+snapshots, as they would be dropped with it: ETITransactionOpen is returned.
+*/
+func (t *Tree) Snapshot(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.journal.tx != nil {
+		return ETITransactionOpen
+	}
+
+	if name == "" {
+		return ETISnapshotNameNotValid
+	}
+
+	t.snapshots = slices.DeleteFunc(t.snapshots, func(s *snapshot) bool { return s.Name == name })
+	t.snapshots = append(t.snapshots, &snapshot{SnapshotInfo{Name: name, Time: t.now()}, t.clone()})
+	return nil
+}
+
+/*
+Returns the snapshots of the tree, oldest first.
+*/
+func (t *Tree) Snapshots() []SnapshotInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	infos := make([]SnapshotInfo, 0, len(t.snapshots))
+	for _, s := range t.snapshots {
+		infos = append(infos, s.SnapshotInfo)
+	}
+
+	return infos
+}
+
+/*
+Returns a copy of the tree as it was when the snapshot name was taken, or ETISnapshotNotFound. Changing the copy does not change the snapshot.
+*/
+func (t *Tree) SnapshotTree(name string) (*Tree, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, err := t.snapshot(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tree.clone(), nil
+}
+
+/*
+Puts the tree back in the state it was when the snapshot name was taken. The journal is emptied, as its operations do not apply to the restored
+tree, while the snapshots are kept. ETITransactionOpen is returned while a transaction is open.
+*/
+func (t *Tree) Restore(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inTransaction() {
+		return ETITransactionOpen
+	}
+
+	s, err := t.snapshot(name)
+	if err != nil {
+		return err
+	}
+
+	defer t.notifyUnrecorded()()
+	t.copyFrom(s.tree)
+	t.journal = journal{limit: t.journal.limit}
+	return nil
+}
+
+func (t *Tree) snapshot(name string) (*snapshot, error) {
+	index := slices.IndexFunc(t.snapshots, func(s *snapshot) bool { return s.Name == name })
+	if index < 0 {
+		return nil, ETISnapshotNotFound
+	}
+
+	return t.snapshots[index], nil
+}
@@ -0,0 +1,190 @@
+package tree
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/notes.txt", []byte("first"))
+	tree.CreateFolder("/", "docs", false)
+	if err := tree.Snapshot("start"); err != nil {
+		t.Fatal(err)
+	}
+	start := saved(t, tree)
+
+	f, err := tree.OpenFile("/notes.txt", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("F"), 0) // changed in place, must not reach the snapshot
+	f.Close()
+	tree.AppendFile("/notes.txt", []byte(" and second"))
+	tree.RemoveFolder("/docs", false)
+	tree.Snapshot("end")
+
+	older, err := tree.SnapshotTree("start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := older.ReadFile("/notes.txt"); string(content) != "first" {
+		t.Fatalf("the snapshot was changed along with the tree: %q", content)
+	}
+
+	older.WriteFile("/notes.txt", []byte("changed copy"))
+	if err := tree.Restore("start"); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := saved(t, tree); state != start {
+		t.Fatalf("the snapshot was not restored:\n%s\nwant\n%s", state, start)
+	}
+
+	if _, err := tree.Undo(); err != ETINothingToUndo {
+		t.Fatalf("expected the journal to be emptied, got %v", err)
+	}
+
+	names := []string{}
+	for _, info := range tree.Snapshots() {
+		names = append(names, info.Name)
+	}
+
+	if !reflect.DeepEqual(names, []string{"start", "end"}) {
+		t.Fatalf("unexpected snapshots %v", names)
+	}
+
+	if err := tree.Restore("missing"); err != ETISnapshotNotFound {
+		t.Fatalf("expected a missing snapshot, got %v", err)
+	}
+
+	if err := tree.Snapshot(""); err != ETISnapshotNameNotValid {
+		t.Fatalf("expected an invalid name, got %v", err)
+	}
+
+	tx, _ := tree.Begin()
+	if err := tree.Restore("end"); err != ETITransactionOpen {
+		t.Fatalf("expected restore to be refused inside a transaction, got %v", err)
+	}
+	tx.Rollback()
+}
+
+func TestDiff(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/src/pkg", "util", true)
+	tree.WriteFile("/src/pkg/util/a.go", []byte("package util"))
+	tree.WriteFile("/src/main.go", []byte("package main"))
+	tree.WriteFile("/tmp.txt", nil)
+	tree.Link("/src/main.go", "/main.link")
+	tree.Snapshot("before")
+
+	tree.Move("/src/pkg", "/lib")
+	tree.WriteFile("/lib/util/a.go", []byte("package util // changed"))
+	tree.Rename("/tmp.txt", "scratch.txt")
+	tree.Chmod("/src/main.go", 0o600)
+	tree.RemoveFile("/main.link")
+	tree.CreateFolder("/", "out", false)
+	tree.Symlink("/lib", "/out/lib")
+
+	before, _ := tree.SnapshotTree("before")
+	got := before.Diff(tree)
+	want := []Difference{
+		{Kind: DiffMoved, Path: "/lib", OldPath: "/src/pkg"},
+		{Kind: DiffModified, Path: "/lib/util/a.go", Fields: []string{"content"}},
+		{Kind: DiffRemoved, Path: "/main.link"},
+		{Kind: DiffAdded, Path: "/out"},
+		{Kind: DiffAdded, Path: "/out/lib"},
+		{Kind: DiffMoved, Path: "/scratch.txt", OldPath: "/tmp.txt"},
+		{Kind: DiffModified, Path: "/src/main.go", Fields: []string{"mode"}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected differences\n%v\nwant\n%v", got, want)
+	}
+
+	if diffs := tree.Diff(tree.Clone()); len(diffs) != 0 {
+		t.Fatalf("a copy should not differ from the tree: %v", diffs)
+	}
+
+	reverse := tree.Diff(before)
+	if !slices.ContainsFunc(reverse, func(d Difference) bool { return reflect.DeepEqual(d, Difference{Kind: DiffAdded, Path: "/main.link"}) }) ||
+		!slices.ContainsFunc(reverse, func(d Difference) bool { return d.Kind == DiffMoved && d.Path == "/src/pkg" && d.OldPath == "/lib" }) {
+		t.Fatalf("unexpected reversed differences %v", reverse)
+	}
+}
+
+/*
+Returns a tree holding /big, a folder with files files spread over ten subfolders, and the empty folder /work, along with a snapshot of it.
+*/
+func bigTree(tb testing.TB, files int) (*Tree, *Tree) {
+	tb.Helper()
+	tree := CreateTree()
+	big, _ := tree.Root().InsertFolder("big")
+	for i := range 10 {
+		sub, _ := big.InsertFolder(fmt.Sprintf("sub%d", i))
+		for j := range files / 10 {
+			file, _ := sub.InsertFile(fmt.Sprintf("f%d", j))
+			file.content = []byte(file.name)
+		}
+	}
+
+	tree.reindex()
+	tree.CreateFolder("/", "work", false)
+	tree.Snapshot("before")
+	before, err := tree.SnapshotTree("before")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tree, before
+}
+
+func TestDiffSkipsUnchangedFolders(t *testing.T) {
+	tree, before := bigTree(t, 1000)
+	tree.Move("/big", "/work")
+	tree.WriteFile("/work/new.txt", []byte("new"))
+	tree.WriteFile("/work/big/sub3/f7", []byte("changed"))
+
+	want := []Difference{
+		{Kind: DiffMoved, Path: "/work/big", OldPath: "/big"},
+		{Kind: DiffModified, Path: "/work/big/sub3/f7", Fields: []string{"content"}},
+		{Kind: DiffAdded, Path: "/work/new.txt"},
+	}
+
+	if got := before.Diff(tree); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected differences\n%v\nwant\n%v", got, want)
+	}
+
+	listed := 0
+	for _, entries := range diffEntries(tree, unchangedFolders(before, tree)) {
+		for _, e := range entries {
+			listed++
+			if strings.Count(e.path, "/") > 3 && !strings.HasPrefix(e.path, "/work/big/sub3/") {
+				t.Errorf("%s is inside an unchanged folder but was listed", e.path)
+			}
+		}
+	}
+
+	if want := 4 + 10 + 100; listed != want { // the changed folders, the subfolders of /big and the files of sub3
+		t.Fatalf("%d nodes were listed, want %d", listed, want)
+	}
+
+	if diffs := before.Diff(before); len(diffs) != 0 {
+		t.Fatalf("a tree differs from itself: %v", diffs)
+	}
+}
+
+func BenchmarkDiffUnchangedSubtree(b *testing.B) {
+	tree, before := bigTree(b, 100_000)
+	tree.WriteFile("/work/new.txt", []byte("new"))
+	b.ResetTimer()
+	for range b.N {
+		if diffs := before.Diff(tree); len(diffs) != 1 {
+			b.Fatalf("unexpected differences %v", diffs)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package tree
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+/*
+SymlinkNode is a concrete implementation of the Node interface that virtually represents a symbolic link. Like files, links are leaf nodes: they
+hold the path of their target, which is absolute if it starts with a bar and relative to the folder holding the link otherwise. The target is
+not required to exist.
+
+Path resolution (FollowPath and every Tree method built on it) follows links transparently, except for the last step in the methods that act on
+the link itself (LfollowPath, Lstat, Readlink, RemoveFile, Move and Rename).
+*/
+type SymlinkNode struct {
+	name   string
+	parent *FolderNode
+	target string
+	*inode
+}
+
+/*
+The maximum amount of links followed while resolving a single path, as in the MAXSYMLINKS limit of Unix systems.
+*/
+const MaxSymlinkHops = 40
+
+// Node interface implementation for SymlinkNode
+func (sn *SymlinkNode) IsFile() bool                     { return false }
+func (sn *SymlinkNode) IsFolder() bool                   { return false }
+func (sn *SymlinkNode) IsSymlink() bool                  { return true }
+func (sn *SymlinkNode) Name() string                     { return sn.name }
+func (sn *SymlinkNode) Parent() *FolderNode              { return sn.parent }
+func (sn *SymlinkNode) AsFile() (*FileNode, error)       { return nil, ETISymlinkAsFile }
+func (sn *SymlinkNode) AsFolder() (*FolderNode, error)   { return nil, ETISymlinkAsFolder }
+func (sn *SymlinkNode) AsSymlink() (*SymlinkNode, error) { return sn, nil }
+func (sn *SymlinkNode) CleanName() string                { return sn.Name() }
+func (sn *SymlinkNode) Stat() *NodeInfo {
+	return newNodeInfo(sn, int64(len(sn.target)), fs.ModeSymlink|sn.meta.mode)
+}
+
+// Stringer interface implementation for SymlinkNode
+func (sn *SymlinkNode) String() string {
+	return fmt.Sprintf("=SYMLINK=\nName: %s\nParent:%s\nTarget: %s\n==", sn.Name(), sn.Parent().Name(), sn.target)
+}
+
+/*
+Returns the path the link points to, exactly as it was given.
+*/
+func (sn *SymlinkNode) Target() string {
+	return sn.target
+}
+
+/*
+Builds a new link named name inside parent, pointing to target. Links always have mode 0777, their permissions are never checked.
+*/
+func NewSymlinkNode(name string, target string, parent *FolderNode) (*SymlinkNode, error) {
+	if parent == nil {
+		return nil, ETIDanglingFile
+	}
+
+	if err := ValidateNodeName(name); err != nil {
+		return nil, err
+	}
+
+	if target == "" {
+		return nil, ETIEmptySymlinkTarget
+	}
+
+	meta := childMeta(parent, 0o777)
+	meta.mode = 0o777
+	return &SymlinkNode{name: name, parent: parent, target: target, inode: newInode(meta)}, nil
+}
+
+/*
+Adds a new link to target as a child of the current folder.
+*/
+func (fn *FolderNode) InsertSymlink(name string, target string) (*SymlinkNode, error) {
+	if fn.childNamed(name) != nil {
+		return nil, ETIDuplicatedName
+	}
+
+	link, err := NewSymlinkNode(name, target, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	fn.addChildren(link)
+	return link, nil
+}
+
+/*
+The state of a single path resolution: how many links were followed so far and which ones are being resolved at the moment. Finding one of the
+latter again means the links form a loop.
+*/
+type linkWalk struct {
+	hops   int
+	active []*SymlinkNode
+}
+
+/*
+Returns the node link points to, following any other link on the way.
+*/
+func (t *Tree) resolveLink(link *SymlinkNode, w *linkWalk) (Node, error) {
+	if slices.Contains(w.active, link) {
+		return nil, ETISymlinkLoop
+	}
+
+	w.hops++
+	if w.hops > MaxSymlinkHops {
+		return nil, ETITooManySymlinks
+	}
+
+	w.active = append(w.active, link)
+	defer func() { w.active = w.active[:len(w.active)-1] }()
+
+	var start Node = link.Parent()
+	if strings.HasPrefix(link.target, "/") {
+		start = t.Root()
+	}
+
+	return t.lookup(splitPath(link.target), start, true, w)
+}
+
+/*
+Creates a symbolic link at path pointing to target, as os.Symlink does. The folder holding path must exist.
+*/
+func (t *Tree) Symlink(target string, linkPath string) (_ *SymlinkNode, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "link %s to %s", linkPath, target)()
+
+	steps := splitPath(linkPath)
+	if len(steps) == 0 {
+		return nil, ETIDuplicatedName
+	}
+
+	node, err := t.followPath(steps[:len(steps)-1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := node.AsFolder()
+	if err != nil {
+		return nil, ETIExpectedFolderFoundFile
+	}
+
+	if err := t.checkModifyFolder(folder); err != nil {
+		return nil, err
+	}
+
+	return folder.InsertSymlink(steps[len(steps)-1], target)
+}
+
+/*
+Returns the target of the link at path, as os.Readlink does.
+*/
+func (t *Tree) Readlink(linkPath string) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	node, err := t.lfollow(linkPath)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := node.AsSymlink()
+	if err != nil {
+		return "", err
+	}
+
+	return link.Target(), nil
+}
+
+/*
+Returns the info of the node at path, following links.
+*/
+func (t *Tree) Stat(nodePath string) (*NodeInfo, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	node, err := t.follow(nodePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Stat(), nil
+}
+
+/*
+Returns the info of the node at path. If it is a link, the info describes the link itself.
+*/
+func (t *Tree) Lstat(nodePath string) (*NodeInfo, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	node, err := t.lfollow(nodePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Stat(), nil
+}
+
+/*
+WalkFunc is called by Walk for every node visited, with the path it was reached through. As with fs.WalkDir, returning fs.SkipDir from a folder
+skips its content, while returning it from a file or link skips the rest of the folder holding it. Any other error stops the walk and is
+returned by Walk.
+*/
+type WalkFunc func(nodePath string, n Node) error
+
+/*
+Visits the node at root and everything under it depth first, in insertion order. Links are reported as links unless followLinks is set, in
+which case they are reported as the node they point to (dangling links are still reported as links) and folders reached through them are
+walked too. A folder that is already being walked is never entered again, so links pointing to their ancestors don't make the walk loop.
+Folders the user cannot read or traverse are reported but not entered. fn is called with the tree locked (see Tree), so it must not call the
+methods of the tree: it can collect what it needs and act on it once Walk returns.
+*/
+func (t *Tree) Walk(root string, followLinks bool, fn WalkFunc) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.walkPath(root, followLinks, fn)
+}
+
+func (t *Tree) walkPath(root string, followLinks bool, fn WalkFunc) error {
+	node, err := t.follow(root)
+	if err != nil {
+		return err
+	}
+
+	err = t.walk(path.Clean("/"+root), node, followLinks, nil, fn)
+	if err == fs.SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+func (t *Tree) walk(nodePath string, n Node, followLinks bool, ancestors []*FolderNode, fn WalkFunc) error {
+	if err := fn(nodePath, n); err != nil {
+		if err == fs.SkipDir && n.IsFolder() {
+			return nil
+		}
+		return err // fs.SkipDir from anything else skips the siblings of n
+	}
+
+	folder, ok := n.(*FolderNode)
+	if !ok || slices.Contains(ancestors, folder) || t.checkAccess(folder, accessRead|accessExec) != nil {
+		return nil
+	}
+
+	ancestors = append(ancestors, folder)
+	for child := range folder.children.all() {
+		childPath := path.Join(nodePath, child.CleanName())
+		if link, ok := child.(*SymlinkNode); ok && followLinks {
+			if target, err := t.resolveLink(link, &linkWalk{}); err == nil {
+				child = target
+			}
+		}
+
+		err := t.walk(childPath, child, followLinks, ancestors, fn)
+		if err == fs.SkipDir {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+/*
+Builds /a/b/f.txt ("data") with the links /abs -> /a/b, /a/rel -> b and /a/b/up -> ../rel.
+*/
+func linkTree(t *testing.T) *Tree {
+	t.Helper()
+	tree := CreateTree()
+	tree.CreateFolder("/a", "b", true)
+	tree.WriteFile("/a/b/f.txt", []byte("data"))
+
+	for link, target := range map[string]string{"/abs": "/a/b", "/a/rel": "b", "/a/b/up": "../rel"} {
+		if _, err := tree.Symlink(target, link); err != nil {
+			t.Fatalf("%s: %v", link, err)
+		}
+	}
+
+	return tree
+}
+
+func TestSymlinkResolution(t *testing.T) {
+	tree := linkTree(t)
+
+	for _, p := range []string{"/abs/f.txt", "/a/rel/f.txt", "/a/b/up/f.txt", "/abs/up/up/up/f.txt", "/a/rel/../b/f.txt"} {
+		content, err := tree.ReadFile(p)
+		if err != nil || string(content) != "data" {
+			t.Errorf("%s: read %q, %v", p, content, err)
+		}
+	}
+
+	if node := mustFollow(t, tree, "/abs"); node != mustFollow(t, tree, "/a/b") {
+		t.Fatalf("/abs was not followed: %v", node)
+	}
+
+	node, err := tree.LfollowPath("/abs")
+	if err != nil || !node.IsSymlink() {
+		t.Fatalf("LfollowPath followed the last link: %v %v", node, err)
+	}
+
+	if info, err := tree.Lstat("/a/b/up"); err != nil || info.Mode()&fs.ModeSymlink == 0 || info.Size() != int64(len("../rel")) {
+		t.Fatalf("unexpected Lstat %v %v", info, err)
+	}
+
+	if info, err := tree.Stat("/a/b/up"); err != nil || !info.IsDir() {
+		t.Fatalf("unexpected Stat %v %v", info, err)
+	}
+
+	if target, err := tree.Readlink("/a/rel"); err != nil || target != "b" {
+		t.Fatalf("unexpected Readlink %q %v", target, err)
+	}
+
+	if _, err := tree.Readlink("/a/b"); !errors.Is(err, ETINotASymlink) {
+		t.Fatalf("expected ETINotASymlink, got %v", err)
+	}
+
+	if _, err := tree.CreateFolder("/abs/new/deep", "leaf", true); err != nil {
+		t.Fatal(err)
+	}
+	mustFollow(t, tree, "/a/b/new/deep/leaf")
+}
+
+func TestSymlinkLoops(t *testing.T) {
+	tree := CreateTree()
+	tree.Symlink("loop2", "/loop1")
+	tree.Symlink("/loop1", "/loop2")
+	tree.Symlink("self/x", "/self")
+
+	for _, p := range []string{"/loop1", "/loop2/x", "/self"} {
+		if _, err := tree.FollowPath(p); err != ETISymlinkLoop {
+			t.Errorf("%s: expected a loop, got %v", p, err)
+		}
+	}
+
+	tree.Symlink("missing", "/dangling")
+	if _, err := tree.FollowPath("/dangling"); err != ETIPathNotFound {
+		t.Fatalf("expected the dangling link to be reported as not found, got %v", err)
+	}
+
+	// A chain longer than the limit, with no loop in it.
+	tree.CreateFolder("/", "end", false)
+	tree.Symlink("end", "/l0")
+	for i := 1; i <= MaxSymlinkHops; i++ {
+		tree.Symlink(fmt.Sprintf("l%d", i-1), fmt.Sprintf("/l%d", i))
+	}
+
+	if _, err := tree.FollowPath(fmt.Sprintf("/l%d", MaxSymlinkHops-1)); err != nil {
+		t.Fatalf("a chain within the limit failed: %v", err)
+	}
+
+	if _, err := tree.FollowPath(fmt.Sprintf("/l%d", MaxSymlinkHops)); err != ETITooManySymlinks {
+		t.Fatalf("expected too many links, got %v", err)
+	}
+}
+
+func TestSymlinkMutations(t *testing.T) {
+	tree := linkTree(t)
+
+	if err := tree.Rename("/abs", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tree.Move("/other", "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if node, _ := tree.LfollowPath("/a/b/other"); node == nil || !node.IsSymlink() {
+		t.Fatalf("the link was not moved itself: %v", node)
+	}
+
+	if err := tree.RemoveFile("/a/rel"); err != nil {
+		t.Fatal(err)
+	}
+
+	mustFollow(t, tree, "/a/b/f.txt")
+	if _, err := tree.FollowPath("/a/b/up"); err != ETIPathNotFound {
+		t.Fatalf("expected /a/b/up to dangle, got %v", err)
+	}
+
+	if _, err := tree.Symlink("x", "/a/b/f.txt"); err != ETIDuplicatedName {
+		t.Fatalf("expected a duplicated name, got %v", err)
+	}
+
+	if _, err := tree.Symlink("", "/empty"); err != ETIEmptySymlinkTarget {
+		t.Fatalf("expected an empty target error, got %v", err)
+	}
+}
+
+func TestSymlinkPersistence(t *testing.T) {
+	tree := linkTree(t)
+
+	var buf bytes.Buffer
+	if err := tree.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target, err := loaded.Readlink("/a/b/up"); err != nil || target != "../rel" {
+		t.Fatalf("link lost in JSON: %q %v", target, err)
+	}
+
+	dot, err := tree.GraphViz(GraphVizOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseDOT(strings.NewReader(dot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := parsed.ReadFile("/abs/f.txt"); err != nil || len(content) != 0 {
+		t.Fatalf("link lost in DOT: %q %v", content, err)
+	}
+
+	if target, err := tree.FS().ReadLink("a/rel"); err != nil || target != "b" {
+		t.Fatalf("unexpected io/fs ReadLink %q %v", target, err)
+	}
+
+	clone := tree.Clone()
+	if target, err := clone.Readlink("/abs"); err != nil || target != "/a/b" {
+		t.Fatalf("link lost in Clone: %q %v", target, err)
+	}
+}
+
+func TestWalkLinks(t *testing.T) {
+	tree := linkTree(t)
+	tree.Symlink("/", "/a/b/root")
+
+	collect := func(follow bool) []string {
+		var paths []string
+		err := tree.Walk("/", follow, func(p string, n Node) error {
+			if n.IsFile() {
+				paths = append(paths, p)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+
+	if physical := collect(false); !slices.Equal(physical, []string{"/a/b/f.txt"}) {
+		t.Fatalf("unexpected physical walk %v", physical)
+	}
+
+	logical := collect(true)
+	// /a/b/up leads back to /a/b, which is being walked, and so do the links under /a/b/root.
+	for _, want := range []string{"/a/b/f.txt", "/a/rel/f.txt", "/abs/f.txt"} {
+		if !slices.Contains(logical, want) {
+			t.Errorf("%s missing from the logical walk %v", want, logical)
+		}
+	}
+
+	if slices.Contains(logical, "/a/b/up/f.txt") {
+		t.Errorf("the walk entered an ancestor again: %v", logical)
+	}
+}
+
+func TestWalkSkipDir(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/a", "skipped", true)
+	tree.WriteFile("/a/skipped/inner", nil)
+	for _, name := range []string{"b", "stop", "c"} {
+		tree.WriteFile("/a/"+name, nil)
+	}
+	tree.CreateFolder("/", "d", false)
+
+	var visited []string
+	err := tree.Walk("/", false, func(p string, n Node) error {
+		visited = append(visited, p)
+		if p == "/a/skipped" || p == "/a/stop" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+
+	// as with fs.WalkDir, SkipDir skips the content of a folder, and the rest of the folder holding a file
+	want := []string{"/", "/a", "/a/skipped", "/a/b", "/a/stop", "/d"}
+	if err != nil || !slices.Equal(visited, want) {
+		t.Fatalf("unexpected walk %q (%v), want %q", visited, err, want)
+	}
+
+	if err := tree.Walk("/a/b", false, func(string, Node) error { return fs.SkipDir }); err != nil {
+		t.Fatalf("SkipDir from a file given as the root should end the walk quietly, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package tree
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+Transaction stages operations on a tree and applies them all or nothing. The operations are made on a private working copy of the tree (see
+Transaction.Tree), so each one sees what the previous ones did while the tree itself, and everyone reading it (other goroutines, File handles,
+snapshots, watchers), sees none of them until Commit. Commit then applies all of them to the tree at once, under a single lock, as a single entry
+of the journal that is undone and redone at once, while Rollback simply drops the working copy.
+
+An operation that fails inside a transaction is undone by itself (like any other operation, see the journal) and leaves the transaction open,
+it is up to the caller to roll it back or go on. Transactions are optimistic: the tree can still be changed while one is open, but then the
+staged operations no longer apply to it and Commit fails with ETITransactionConflict, leaving the tree untouched.
+*/
+type Transaction struct {
+	tree    *Tree
+	work    *Tree
+	start   time.Time
+	version uint64 // of the tree when the transaction was opened
+	steps   []*journalEntry
+}
+
+/*
+Opens a transaction on the tree. Only one transaction can be open at a time, ETITransactionOpen is returned otherwise (as it is when t is itself
+the working copy of a transaction).
+*/
+func (t *Tree) Begin() (*Transaction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inTransaction() {
+		return nil, ETITransactionOpen
+	}
+
+	tx := &Transaction{tree: t, work: t.clone(), start: t.now(), version: t.version}
+	tx.work.lastIno = t.lastIno
+	tx.work.journal.tx = tx
+	t.journal.open = tx
+	return tx, nil
+}
+
+/*
+Returns true if a transaction is open on t or if t is the working copy of one. Operations that cannot be staged, such as Undo, Restore or the
+ones of the repository, are refused meanwhile.
+*/
+func (t *Tree) inTransaction() bool {
+	return t.journal.open != nil || t.journal.tx != nil
+}
+
+/*
+Returns the working copy of the tree the operations of the transaction are made on. It starts as a copy of the tree without its journal,
+snapshots and repository, and must not be used once the transaction is closed, nor can the File handles opened on it.
+*/
+func (tx *Transaction) Tree() *Tree {
+	return tx.work
+}
+
+/*
+Returns the description of the operations made in the transaction so far, in the order they were made.
+*/
+func (tx *Transaction) Operations() []string {
+	tx.work.mu.RLock()
+	defer tx.work.mu.RUnlock()
+	return tx.operations()
+}
+
+func (tx *Transaction) operations() []string {
+	ops := make([]string, 0, len(tx.steps))
+	for _, step := range tx.steps {
+		ops = append(ops, step.Op)
+	}
+
+	return ops
+}
+
+/*
+Closes the transaction applying everything done in it to the tree, as a single entry of the journal. ETITransactionClosed is returned if the
+transaction was already committed or rolled back, and ETITransactionConflict if the tree was changed since the transaction was opened, in which
+case nothing is applied and the transaction is closed all the same.
+*/
+func (tx *Transaction) Commit() error {
+	tx.tree.mu.Lock()
+	defer tx.tree.mu.Unlock()
+	tx.work.mu.Lock()
+	defer tx.work.mu.Unlock()
+
+	if tx.tree.journal.open != tx {
+		return ETITransactionClosed
+	}
+
+	tx.tree.journal.open = nil
+	if len(tx.steps) == 0 {
+		return nil
+	}
+
+	if tx.tree.version != tx.version {
+		return ETITransactionConflict
+	}
+
+	// The working copy is taken back to where it started, so its nodes match the ones of the tree, and the operations are made again on the tree.
+	if err := tx.work.replaySteps(tx.steps, true); err != nil {
+		return err
+	}
+
+	steps, err := tx.tree.stagedSteps(tx.work, tx.steps)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.tree.replaySteps(steps, false); err != nil {
+		return err
+	}
+
+	tx.tree.lastIno = max(tx.tree.lastIno, tx.work.lastIno)
+	entry := &journalEntry{
+		HistoryEntry: HistoryEntry{Op: "transaction: " + strings.Join(tx.operations(), ", "), Time: tx.start},
+		steps:        steps,
+	}
+	tx.tree.push(entry)
+	tx.tree.notifyEntry(entry, false)
+	return nil
+}
+
+/*
+Closes the transaction dropping everything done in it, the tree is left as it is. ETITransactionClosed is returned if the transaction was
+already committed or rolled back.
+*/
+func (tx *Transaction) Rollback() error {
+	tx.tree.mu.Lock()
+	defer tx.tree.mu.Unlock()
+
+	if tx.tree.journal.open != tx {
+		return ETITransactionClosed
+	}
+
+	tx.tree.journal.open = nil
+	return nil
+}
+
+/*
+Rewrites the entries recorded on work, a working copy taken back to the state t is in, so they apply to t: the nodes and inodes of work are
+replaced by the ones of t they were copied from, matched by path and inode number, while the ones created in the transaction are moved over as
+they are. ETITransactionConflict is returned if the two trees do not match.
+*/
+func (t *Tree) stagedSteps(work *Tree, steps []*journalEntry) ([]*journalEntry, error) {
+	nodes := map[Node]Node{}
+	var match func(w Node, n Node) bool
+	match = func(w Node, n Node) bool {
+		if n == nil || entryKind(w) != entryKind(n) || inodeOf(w).ino != inodeOf(n).ino {
+			return false
+		}
+
+		nodes[w] = n
+		if folder, ok := w.(*FolderNode); ok {
+			if folder.children.len() != n.(*FolderNode).children.len() {
+				return false
+			}
+
+			for child := range folder.children.all() {
+				if !match(child, n.(*FolderNode).childNamed(child.CleanName())) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if !match(work.Root(), t.Root()) {
+		return nil, ETITransactionConflict
+	}
+
+	node := func(n Node) Node {
+		if matched, ok := nodes[n]; ok {
+			return matched
+		}
+		return n
+	}
+
+	inodes := func(in *inode) *inode {
+		if matched, ok := t.inodes[in.ino]; ok {
+			return matched
+		}
+		return in
+	}
+
+	staged := make([]*journalEntry, 0, len(steps))
+	for _, step := range steps {
+		entry := &journalEntry{
+			HistoryEntry: step.HistoryEntry,
+			before:       make(map[*inode]inodeState, len(step.inodes)),
+			after:        make(map[*inode]inodeState, len(step.inodes)),
+		}
+
+		for _, c := range step.changes {
+			switch c := c.(type) {
+			case placement:
+				c.node, c.next = node(c.node), node(c.next)
+				c.parent = node(c.parent).(*FolderNode)
+				entry.changes = append(entry.changes, c)
+			case renaming:
+				c.node = node(c.node)
+				entry.changes = append(entry.changes, c)
+			}
+		}
+
+		for i, in := range step.inodes {
+			matched := inodes(in)
+			entry.inodes = append(entry.inodes, matched)
+			entry.nodes = append(entry.nodes, node(step.nodes[i]))
+			entry.before[matched], entry.after[matched] = step.before[in], step.after[in]
+		}
+		staged = append(staged, entry)
+	}
+
+	return staged, nil
+}
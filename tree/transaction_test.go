@@ -0,0 +1,164 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestTransactions(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/keep.txt", []byte("kept"))
+	before := saved(t, tree)
+
+	tx, err := tree.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tree.Begin(); err != ETITransactionOpen {
+		t.Fatalf("expected a single transaction, got %v", err)
+	}
+
+	work := tx.Tree()
+	work.CreateFolder("/app/config", "env", true)
+	work.WriteFile("/app/config/env/prod", []byte("x"))
+	work.Move("/keep.txt", "/app")
+	if _, err := work.CreateFile("/missing", "f"); err == nil {
+		t.Fatal("expected the creation to fail")
+	}
+
+	if state := saved(t, tree); state != before {
+		t.Fatalf("the staged operations leaked into the tree:\n%s", state)
+	}
+
+	if _, err := work.Undo(); err != ETITransactionOpen {
+		t.Fatalf("expected undo to be refused inside a transaction, got %v", err)
+	}
+
+	if _, err := tree.Undo(); err != ETITransactionOpen {
+		t.Fatalf("expected undo to be refused while a transaction is open, got %v", err)
+	}
+
+	if ops := tx.Operations(); len(ops) != 3 {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := saved(t, tree); state != before {
+		t.Fatalf("the rollback did not restore the tree:\n%s\nwant\n%s", state, before)
+	}
+
+	if err := tx.Commit(); err != ETITransactionClosed {
+		t.Fatalf("expected the transaction to be closed, got %v", err)
+	}
+
+	tx, _ = tree.Begin()
+	tx.Tree().CreateFolder("/", "a", false)
+	tx.Tree().CreateFolder("/a", "b", false)
+	if _, err := tree.FollowPath("/a"); err == nil {
+		t.Fatal("the tree changed before the commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	done, _ := tree.History()
+	if len(done) != 2 || done[1].Op != "transaction: create folder /a, create folder /a/b" {
+		t.Fatalf("the transaction is not a single entry: %v", done)
+	}
+
+	tree.Undo()
+	if state := saved(t, tree); state != before {
+		t.Fatalf("undoing the transaction did not undo all of it:\n%s", state)
+	}
+
+	tree.Redo()
+	mustFollow(t, tree, "/a/b")
+}
+
+func TestTransactionKeepsNodes(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/f", []byte("old"))
+	node := mustFollow(t, tree, "/f")
+
+	tx, _ := tree.Begin()
+	work := tx.Tree()
+	work.WriteFile("/f", []byte("new"))
+	work.CreateFolder("/", "d", false)
+	work.Move("/f", "/d")
+	work.Chmod("/d/f", 0o600)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if moved := mustFollow(t, tree, "/d/f"); moved != node {
+		t.Fatal("the commit replaced the node of the tree with the one of the working copy")
+	}
+
+	if data, _ := tree.ReadFile("/d/f"); string(data) != "new" || node.Stat().Mode().Perm() != 0o600 {
+		t.Fatalf("the commit lost changes: %q %v", data, node.Stat().Mode())
+	}
+
+	if n := tree.InodeCount(); n != 3 {
+		t.Fatalf("unexpected inode count %d", n)
+	}
+
+	tree.Undo()
+	if data, _ := tree.ReadFile("/f"); string(data) != "old" {
+		t.Fatalf("undoing the transaction did not restore the file: %q", data)
+	}
+}
+
+func TestTransactionConflict(t *testing.T) {
+	tree := CreateTree()
+	tx, _ := tree.Begin()
+	tx.Tree().CreateFolder("/", "a", false)
+	tree.WriteFile("/b", nil) // a change made behind the transaction
+	before := saved(t, tree)
+
+	if err := tx.Commit(); err != ETITransactionConflict {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	if state := saved(t, tree); state != before {
+		t.Fatalf("the conflicting transaction changed the tree:\n%s", state)
+	}
+
+	if _, err := tree.Begin(); err != nil {
+		t.Fatalf("the conflicting transaction was left open: %v", err)
+	}
+}
+
+func TestFailedOperationsAreAtomic(t *testing.T) {
+	tree := CreateTree()
+	tree.Chmod("/", 0o777)
+	tree.SetUser(alice)
+	tree.SetUmask(0o577) // folders created by alice can't be written to
+	before := saved(t, tree)
+
+	if _, err := tree.CreateFolder("/a/b/c", "d", true); err != ETIPermissionDenied {
+		t.Fatalf("expected the second folder to be denied, got %v", err)
+	}
+
+	if state := saved(t, tree); state != before || tree.InodeCount() != 1 {
+		t.Fatalf("the failed creation left folders behind:\n%s", state)
+	}
+
+	tree.SetUmask(DefaultUmask)
+	if _, err := tree.CreateFolder("/x/y", "..", true); err != ETINameNotValid {
+		t.Fatalf("expected an invalid name, got %v", err)
+	}
+
+	if _, err := tree.FollowPath("/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("the failed creation left /x behind: %v", err)
+	}
+
+	if done, _ := tree.History(); len(done) != 1 { // the chmod
+		t.Fatalf("failed operations were recorded: %v", done)
+	}
+}
@@ -3,7 +3,7 @@ The tree package implements everything needed under the hood as well as the user
 
 It currently provides:
 - the Node interface
-- the FolderNode and FileNode implementations of the Node interface
+- the FolderNode, FileNode and SymlinkNode implementations of the Node interface
 - Tree editing and traversal functions
 
 Usage:
@@ -13,22 +13,98 @@ t := tree.CreateTree()
 package tree
 
 import (
-	"fmt"
+	"io/fs"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
+/*
+A Tree is safe for concurrent use by multiple goroutines: its methods, along with those of its Repository, Transactions, open Files and FS, are
+guarded by a single tree-wide lock. Methods that only look at the tree share it, while methods that change the tree (reading a file or a folder
+does, as it updates the access time) hold it alone. There is no other lock, so there is no lock ordering to keep: methods taking a second tree
+(Diff and Graft) copy it before locking t.
+
+The nodes returned by a Tree are not guarded, though. Reading them while other goroutines change the tree, or changing them directly through
+their own methods (InsertFile, RemoveNode, Write, ...), is a data race: trees shared across goroutines should be used through the Tree methods only.
+*/
 type Tree struct {
-	root FolderNode
+	mu        sync.RWMutex
+	root      FolderNode
+	clock     Clock
+	user      User
+	umask     fs.FileMode
+	inodes    map[uint64]*inode // see inode
+	lastIno   uint64
+	journal   journal
+	snapshots []*snapshot // oldest first
+	repo      *Repository // see Repository
+	watchers  []*Watcher  // see Watch
+	hashEpoch uint64      // see invalidateHash
+	version   uint64      // bumped by every change, see Transaction
 }
 
 /*
 Creates an empty tree with the root node already set.
 */
 func CreateTree() *Tree {
-	return &Tree{
-		root: *createRootFolder(),
+	return CreateTreeWithClock(SystemClock)
+}
+
+/*
+Creates an empty tree whose timestamps come from clock.
+*/
+func CreateTreeWithClock(clock Clock) *Tree {
+	t := &Tree{clock: clock, user: RootUser, umask: DefaultUmask, journal: journal{limit: DefaultJournalLimit}}
+	t.resetRoot()
+	return t
+}
+
+/*
+Replaces the root with a new empty one, discarding the whole content of the tree along with its journal, snapshots and repository.
+*/
+func (t *Tree) resetRoot() {
+	t.root = *createRootFolder()
+	t.root.tree = t
+	t.root.meta = newNodeMeta(t.now(), RootUser.Name, RootUser.primaryGroup(), 0o755)
+	t.inodes, t.lastIno = nil, 0
+	t.journal = journal{limit: t.journal.limit}
+	t.snapshots, t.repo = nil, nil
+	t.version++
+	t.register(t.Root())
+}
+
+/*
+Returns a deep copy of the whole tree. Nothing is shared between the trees, so changing one of them does not affect the other. Inode numbers are
+kept, so the nodes of both trees can be matched by them (see Diff). The journal, the snapshots and the repository are not copied, the clone starts without them.
+*/
+func (t *Tree) Clone() *Tree {
+	t.mu.Lock() // the contents become shared, see inode.clone
+	defer t.mu.Unlock()
+	return t.clone()
+}
+
+func (t *Tree) clone() *Tree {
+	clone := CreateTreeWithClock(t.clock)
+	clone.user, clone.umask, clone.journal.limit = t.user, t.umask, t.journal.limit
+	clone.copyFrom(t)
+	return clone
+}
+
+/*
+Replaces the root and everything under it with a copy of the ones of src, keeping their inode numbers.
+*/
+func (t *Tree) copyFrom(src *Tree) {
+	inodes := inodeCopies{}
+	t.root.inode = inodes.get(src.root.inode)
+	t.root.children = childList{}
+	for child := range src.root.children.all() {
+		t.root.children.insert(cloneNode(child, t.Root(), inodes), nil)
 	}
+
+	inodes.keepHashes(src, t)
+	t.version++
+	t.reindex()
 }
 
 // Field Accessors
@@ -36,23 +112,69 @@ func (t *Tree) Root() *FolderNode {
 	return &t.root
 }
 
+/*
+Converts a path string into the list of steps understood by followPath and explorePath. Absolute paths ("/a/b"), root relative paths ("./a/b" and "a/b")
+and the root itself ("", "." and "/") are all accepted. Empty and "." steps are dropped, ".." steps are kept and resolved during the navigation.
+*/
+func splitPath(path string) []string {
+	path = filepath.ToSlash(path)
+
+	steps := []string{}
+	for _, step := range strings.Split(path, "/") {
+		if step == "" || step == "." {
+			continue
+		}
+		steps = append(steps, step)
+	}
+
+	return steps
+}
+
+/*
+Returns true if the step does not move the navigation (i.e it is empty or ".").
+*/
+func isStayStep(step string) bool {
+	step = strings.TrimSuffix(step, "/")
+	return step == "" || step == "."
+}
+
+/*
+Returns the folder reached by a ".." step taken from folder. The root is its own parent.
+*/
+func parentStep(folder *FolderNode) *FolderNode {
+	if folder.HasParent() {
+		return folder.Parent()
+	}
+
+	return folder
+}
+
 /*
 This function starts a navigation from the root path up to the final path in the string, returning it if it exists or an error if anything on the path
-does not exist or is a file (except the last, which can bea file). The received path must be sanitized to remove the trailing bar
+does not exist or is a file (except the last, which can bea file). Steps may carry a trailing bar, empty and "." steps are ignored and ".." goes up one level.
+Symbolic links are followed, including the last one.
 */
 func (t *Tree) followPath(path []string, current_node Node) (Node, error) {
+	return t.lookup(path, current_node, true, &linkWalk{})
+}
+
+/*
+The navigation behind followPath. Links found on the way are resolved (see resolveLink), the one reached by the last step only if followLast is set.
+*/
+func (t *Tree) lookup(path []string, current_node Node, followLast bool, w *linkWalk) (Node, error) {
 	if current_node == nil {
 		current_node = t.Root()
-		if path[0] == "." {
-			path = path[1:]
-		}
+	}
+
+	for len(path) > 0 && isStayStep(path[0]) {
+		path = path[1:]
 	}
 
 	if len(path) == 0 {
 		return current_node, nil
 	}
 
-	if current_node.IsFile() {
+	if !current_node.IsFolder() {
 		return nil, ETIUnableToFollow
 	}
 
@@ -61,61 +183,87 @@ func (t *Tree) followPath(path []string, current_node Node) (Node, error) {
 		return nil, err
 	}
 
+	if err := t.checkAccess(folder, accessExec); err != nil {
+		return nil, err
+	}
+
+	evaluatedStep := strings.TrimSuffix(path[0], "/")
+	nextSteps := path[1:]
+	if evaluatedStep == ".." {
+		return t.lookup(nextSteps, parentStep(folder), followLast, w)
+	}
+
 	if !folder.HasChildren() {
 		return nil, ETIUnableToFollow
 	}
 
-	children, err := folder.GetChildren()
-	if err != nil {
-		return nil, err
+	child := folder.childNamed(evaluatedStep)
+	if child == nil {
+		return nil, ETIPathNotFound
 	}
 
-	evaluatedStep := path[0]
-	nextSteps := path[1:]
-	for _, child := range children {
-		if child.CleanName() == strings.TrimSuffix(evaluatedStep, "/") {
-			return t.followPath(nextSteps, child)
+	if link, ok := child.(*SymlinkNode); ok && (len(nextSteps) > 0 || followLast) {
+		if child, err = t.resolveLink(link, w); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil, ETIPathNotFound
+	return t.lookup(nextSteps, child, followLast, w)
 }
 
 /* Interface to the internal followPath funciton */
 func (t *Tree) FollowPath(path string) (Node, error) {
-	path = filepath.ToSlash(path)
-	path = strings.TrimSuffix(path, "/")
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.follow(path)
+}
 
-	separatePath := strings.SplitAfter(path, "/")
-	return t.followPath(separatePath, nil)
+func (t *Tree) follow(path string) (Node, error) {
+	return t.followPath(splitPath(path), nil)
+}
+
+/*
+Same as FollowPath, except that a symbolic link reached by the last step is returned itself instead of being followed (as Lstat does).
+*/
+func (t *Tree) LfollowPath(path string) (Node, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lfollow(path)
+}
+
+func (t *Tree) lfollow(path string) (Node, error) {
+	return t.lookup(splitPath(path), nil, false, &linkWalk{})
 }
 
 /* Interface to the internal explorePath function */
 func (t *Tree) ExplorePath(path string) (Node, []string, error) {
-	path = filepath.ToSlash(path)
-	path = strings.TrimSuffix(path, "/")
-
-	separatePath := strings.SplitAfter(path, "/")
-	return t.explorePath(separatePath, nil)
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.explorePath(splitPath(path), nil)
 }
 
 /*
 [UT] Follows a path up to the point it's not possible anymore (i.e the rest of the path doesn't exist). Returns the most distant existent element
-in the path and the portion of the path that is missing.
+in the path and the portion of the path that is missing. Symbolic links are followed and one that cannot be resolved is an error.
 */
 func (t *Tree) explorePath(path []string, current_node Node) (Node, []string, error) {
+	return t.explore(path, current_node, &linkWalk{})
+}
+
+func (t *Tree) explore(path []string, current_node Node, w *linkWalk) (Node, []string, error) {
 	if current_node == nil {
 		current_node = t.Root()
-		if path[0] == "." {
-			path = path[1:]
-		}
+	}
+
+	for len(path) > 0 && isStayStep(path[0]) {
+		path = path[1:]
 	}
 
 	if len(path) == 0 { // All locations exist
 		return current_node, path, nil
 	}
 
-	if current_node.IsFile() {
+	if !current_node.IsFolder() {
 		return nil, nil, ETIUnableToFollow
 	}
 
@@ -124,26 +272,32 @@ func (t *Tree) explorePath(path []string, current_node Node) (Node, []string, er
 		return nil, nil, ETIUnableToFollow
 	}
 
-	if !folder.HasChildren() {
-		return current_node, path, nil
-	}
-
-	children, err := folder.GetChildren()
-	if err != nil {
+	if err := t.checkAccess(folder, accessExec); err != nil {
 		return nil, nil, err
 	}
 
 	evaluatedStep := strings.TrimSuffix(path[0], "/")
 	nextSteps := path[1:]
+	if evaluatedStep == ".." {
+		return t.explore(nextSteps, parentStep(folder), w)
+	}
+
+	if !folder.HasChildren() {
+		return current_node, path, nil
+	}
+
+	child := folder.childNamed(evaluatedStep)
+	if child == nil {
+		return current_node, path, nil
+	}
 
-	for _, child := range children {
-		fmt.Printf("CleanName: %s | Evaluated step: %s\n", child.CleanName(), evaluatedStep)
-		if child.CleanName() == evaluatedStep {
-			return t.explorePath(nextSteps, child)
+	if link, ok := child.(*SymlinkNode); ok {
+		if child, err = t.resolveLink(link, w); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	return current_node, nextSteps, nil
+	return t.explore(nextSteps, child, w)
 
 }
 
@@ -151,11 +305,15 @@ func (t *Tree) explorePath(path []string, current_node Node) (Node, []string, er
 Creates a file node at the given path.
 */
 func (t *Tree) CreateFile(path string, name string) (*FileNode, error) {
-	path = filepath.ToSlash(path)
-	path = strings.TrimSuffix(path, "/")
-	pathSeparated := strings.Split(path, "/")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.createFile(path, name)
+}
 
-	node, err := t.followPath(pathSeparated, nil)
+func (t *Tree) createFile(path string, name string) (_ *FileNode, err error) {
+	defer t.record(&err, "create file %s", joinStep(path, name))()
+
+	node, err := t.follow(path)
 	if err != nil {
 		return nil, err
 	}
@@ -169,6 +327,10 @@ func (t *Tree) CreateFile(path string, name string) (*FileNode, error) {
 		return nil, err
 	}
 
+	if err := t.checkModifyFolder(fnode); err != nil {
+		return nil, err
+	}
+
 	created, err := fnode.InsertFile(name)
 	if err != nil {
 		return nil, err
@@ -180,12 +342,14 @@ func (t *Tree) CreateFile(path string, name string) (*FileNode, error) {
 /*
 Creates a folder at a given path. If recursive is false, the function will fail if any of the path's folders but the last does not exist.
 */
-func (t *Tree) CreateFolder(path string, name string, recursive bool) (*FolderNode, error) {
-	path = filepath.ToSlash(path)
+func (t *Tree) CreateFolder(path string, name string, recursive bool) (_ *FolderNode, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "create folder %s", joinStep(path, name))()
 
 	var createAt *FolderNode
 	if !recursive {
-		final, err := t.FollowPath(path)
+		final, err := t.follow(path)
 		if err != nil {
 			return nil, err
 		}
@@ -195,12 +359,11 @@ func (t *Tree) CreateFolder(path string, name string, recursive bool) (*FolderNo
 			return nil, err
 		}
 	} else {
-		furthestNode, pathLeft, err := t.ExplorePath(path)
+		furthestNode, pathLeft, err := t.explorePath(splitPath(path), nil)
 		if err != nil {
 			return nil, err
 		}
 
-		fmt.Printf("furthestNode: %s\n\npathLeft: %s\n", furthestNode.Name(), pathLeft)
 		if furthestNode.IsFile() {
 			return nil, ETIExpectedFolderFoundFile
 		}
@@ -214,8 +377,20 @@ func (t *Tree) CreateFolder(path string, name string, recursive bool) (*FolderNo
 		for len(pathLeft) > 0 {
 
 			creatingNow := strings.TrimSuffix(pathLeft[0], "/")
-			fmt.Printf("Creating now: %s, pathleft: %s\n\n", creatingNow, pathLeft)
 			pathLeft = pathLeft[1:]
+			if isStayStep(creatingNow) {
+				continue
+			}
+
+			if creatingNow == ".." {
+				currentFolder = parentStep(currentFolder)
+				continue
+			}
+
+			if err := t.checkModifyFolder(currentFolder); err != nil {
+				return nil, err
+			}
+
 			currentFolder, err = currentFolder.InsertFolder(creatingNow)
 
 			if err != nil {
@@ -227,28 +402,46 @@ func (t *Tree) CreateFolder(path string, name string, recursive bool) (*FolderNo
 		createAt = currentFolder
 	}
 
+	if err := t.checkModifyFolder(createAt); err != nil {
+		return nil, err
+	}
+
 	return createAt.InsertFolder(name)
 }
 
-func (t *Tree) RemoveFile(path string) error {
-	node, err := t.FollowPath(path)
+/*
+Removes the file at path. If path is a symbolic link the link itself is removed, whatever it points to.
+*/
+func (t *Tree) RemoveFile(path string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "remove %s", path)()
+
+	node, err := t.lfollow(path)
 	if err != nil {
 		return err
 	}
 
-	fil, err := node.AsFile()
-	if err != nil {
+	if !node.IsSymlink() {
+		if node, err = node.AsFile(); err != nil {
+			return err
+		}
+	}
+
+	filParent := node.Parent()
+	if err := t.checkModifyFolder(filParent); err != nil {
 		return err
 	}
 
-	base := filepath.Base(path)
-	filParent := fil.Parent()
-	filParent.RemoveNode(base)
-	return nil
+	return filParent.RemoveNode(node.CleanName())
 }
 
-func (t *Tree) RemoveFolder(path string, recursive bool) error {
-	node, err := t.FollowPath(path)
+func (t *Tree) RemoveFolder(path string, recursive bool) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "remove folder %s", path)()
+
+	node, err := t.follow(path)
 	if err != nil {
 		return err
 	}
@@ -266,17 +459,289 @@ func (t *Tree) RemoveFolder(path string, recursive bool) error {
 		return ETICannotRemoveRoot
 	}
 
-	base := filepath.Base(path)
 	folderParent := folder.Parent()
+	if err := t.checkModifyFolder(folderParent); err != nil {
+		return err
+	}
 
-	if err := folderParent.RemoveNode(base); err != nil {
+	if err := t.checkRemoveAll(folder); err != nil {
+		return err
+	}
+
+	if err := folderParent.RemoveNode(folder.CleanName()); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+/*
+Moves the node at src to dst, the same way mv does: if dst is an existing folder the node is moved into it keeping its name, otherwise dst is the new
+path of the node (and its folder must exist). An existing node with the same name at the destination is never overwritten, ETIDuplicatedName is
+returned instead (see Replace). Folders cannot be moved into themselves or their descendants. A symbolic link at src is moved itself, not its
+target.
+*/
+func (t *Tree) Move(src string, dst string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "move %s to %s", src, dst)()
+	return t.move(src, dst, false)
+}
+
+/*
+Moves the node at src to dst like Move, but a node with the same name at the destination is replaced instead, as mv -f does: a file (or link) can
+only replace a file (or link), and a folder can only replace an empty folder, ETIDuplicatedName is returned otherwise. Everything is checked
+before anything changes, and the replaced node and the move are a single entry of the journal, so undoing it puts both nodes back.
+*/
+func (t *Tree) Replace(src string, dst string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "move %s over %s", src, dst)()
+	return t.move(src, dst, true)
+}
+
+func (t *Tree) move(src string, dst string, replace bool) error {
+	node, err := t.lfollow(src)
+	if err != nil {
+		return err
+	}
+
+	if node == t.Root() {
+		return ETICannotMoveRoot
+	}
+
+	destFolder, name, err := t.destination(dst, node.CleanName())
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateNodeName(name); err != nil {
+		return err
+	}
+
+	if folder, ok := node.(*FolderNode); ok && destFolder.isWithin(folder) {
+		return ETIMoveIntoDescendant
+	}
+
+	existing := destFolder.childNamed(name)
+	if existing == node {
+		return nil
+	}
+
+	if existing != nil && (!replace || existing.IsFolder() != node.IsFolder() || existing.IsFolder() && existing.(*FolderNode).HasChildren()) {
+		return ETIDuplicatedName
+	}
+
+	if err := t.checkModifyFolder(node.Parent()); err != nil {
+		return err
+	}
+
+	if err := t.checkModifyFolder(destFolder); err != nil {
+		return err
+	}
+
+	if existing != nil {
+		if err := destFolder.RemoveNode(name); err != nil {
+			return err
+		}
+	}
+
+	return destFolder.adopt(node, name)
+}
+
+/*
+Renames the node at path to newName without moving it to another folder. A symbolic link at path is renamed itself, not its target.
+*/
+func (t *Tree) Rename(path string, newName string) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "rename %s to %s", path, newName)()
+
+	if err := ValidateNodeName(newName); err != nil {
+		return err
+	}
+
+	node, err := t.lfollow(path)
+	if err != nil {
+		return err
+	}
+
+	if node == t.Root() {
+		return ETICannotMoveRoot
+	}
+
+	if node.CleanName() == newName {
+		return nil
+	}
+
+	if node.Parent().childNamed(newName) != nil {
+		return ETIDuplicatedName
+	}
+
+	if err := t.checkModifyFolder(node.Parent()); err != nil {
+		return err
+	}
+
+	setNodeName(node, newName)
+	return nil
+}
+
+/*
+Copies the node at src to dst, following the same destination rules as Move. Folders are only copied if recursive is true, in which case the whole
+subtree is duplicated. An existing node with the same name at the destination is never overwritten, ETIDuplicatedName is returned instead.
+*/
+func (t *Tree) Copy(src string, dst string, recursive bool) (_ Node, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "copy %s to %s", src, dst)()
+
+	node, err := t.follow(src)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, isFolder := node.(*FolderNode)
+	if isFolder && !recursive {
+		return nil, ETICopyFolderNotRecursive
+	}
+
+	name := node.CleanName()
+	if node == t.Root() {
+		name = ""
+	}
+
+	destFolder, name, err := t.destination(dst, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateNodeName(name); err != nil {
+		return nil, err
+	}
+
+	if isFolder && destFolder.isWithin(folder) {
+		return nil, ETICopyIntoDescendant
+	}
+
+	if destFolder.childNamed(name) != nil {
+		return nil, ETIDuplicatedName
+	}
+
+	if err := t.checkModifyFolder(destFolder); err != nil {
+		return nil, err
+	}
+
+	if err := t.checkReadAll(node); err != nil {
+		return nil, err
+	}
+
+	clone := cloneNode(node, destFolder, inodeCopies{})
+	setNodeName(clone, name)
+	restamp(clone, t.now(), t.user)
+	destFolder.addChildren(clone)
+	return clone, nil
+}
+
+/*
+Copies everything inside the root of src into the folder at path. If any of src's top level names is already taken in the folder, nothing is
+copied and ETIDuplicatedName is returned.
+*/
+func (t *Tree) Graft(path string, src *Tree) (err error) {
+	src = src.Clone() // before locking t, src may be t itself
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "graft a tree into %s", path)()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return err
+	}
+
+	folder, err := node.AsFolder()
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkModifyFolder(folder); err != nil {
+		return err
+	}
+
+	for child := range src.root.children.all() {
+		if folder.childNamed(child.CleanName()) != nil {
+			return ETIDuplicatedName
+		}
+	}
+
+	for child := range src.root.children.all() {
+		setNodeParent(child, folder)
+		folder.addChildren(child)
+	}
+
+	return nil
+}
+
+/*
+Works out where a node should land when dst is used as a destination: inside dst (keeping name) if it is an existing folder, or inside dst's folder
+using dst's base name otherwise. An existing file at dst is not an error here, it is up to the caller to decide what to do with it.
+*/
+func (t *Tree) destination(dst string, name string) (*FolderNode, string, error) {
+	target, err := t.follow(dst)
+	if err == nil {
+		if target.IsFile() {
+			return target.Parent(), target.CleanName(), nil
+		}
+
+		folder, err := target.AsFolder()
+		return folder, name, err
+	}
+
+	steps := splitPath(dst)
+	if len(steps) == 0 {
+		return nil, "", err
+	}
+
+	parent, perr := t.followPath(steps[:len(steps)-1], nil)
+	if perr != nil {
+		return nil, "", perr
+	}
+
+	folder, perr := parent.AsFolder()
+	if perr != nil {
+		return nil, "", ETIExpectedFolderFoundFile
+	}
+
+	return folder, steps[len(steps)-1], nil
+}
+
+/*
+Returns the children of the folder at path, in insertion order. Listing a folder counts as reading it.
+*/
+func (t *Tree) ReadDir(path string) ([]Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := node.AsFolder()
+	if err != nil {
+		return nil, ETIExpectedFolderFoundFile
+	}
+
+	if err := t.checkAccess(folder, accessRead); err != nil {
+		return nil, err
+	}
+
+	folder.meta.accessed(t.now())
+	return folder.GetChildren()
+}
+
 func (t *Tree) EvaluateNodePath(node Node) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
 	currNode := node
 	currPath := currNode.Name()
 
@@ -294,14 +759,123 @@ func (t *Tree) EvaluateNodePath(node Node) string {
 	return currPath
 }
 
-func (t *Tree) SearchAll(str string) ([]Node, error) {
-	res, err := t.Root().DFS(str)
+//func (t *Tree) SearchFile(str string) []FileNode     { return nil }
+//func (t *Tree) SearchFolder(str string) []FolderNode { return nil }
+
+/*
+Returns the file node at path, failing if the path does not exist or leads to a folder.
+*/
+func (t *Tree) fileAt(path string) (*FileNode, error) {
+	node, err := t.follow(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return res, nil
+	if node.IsFolder() {
+		return nil, ETIExpectedFileFoundFolder
+	}
+
+	return node.AsFile()
 }
 
-//func (t *Tree) SearchFile(str string) []FileNode     { return nil }
-//func (t *Tree) SearchFolder(str string) []FolderNode { return nil }
+/*
+Returns the file node at path. If it does not exist but its folder does, an empty file is created first (the same way a shell redirection would).
+*/
+func (t *Tree) fileAtOrCreate(path string) (*FileNode, error) {
+	file, err := t.fileAt(path)
+	if err != ETIPathNotFound && err != ETIUnableToFollow {
+		return file, err
+	}
+
+	return t.createFileAt(path)
+}
+
+/*
+Creates an empty file at path, whose folder must exist.
+*/
+func (t *Tree) createFileAt(path string) (*FileNode, error) {
+	path = strings.TrimSuffix(filepath.ToSlash(path), "/")
+	return t.createFile(filepath.ToSlash(filepath.Dir(path)), filepath.Base(path))
+}
+
+/*
+Returns a copy of the content of the file at path.
+*/
+func (t *Tree) ReadFile(path string) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	file, err := t.fileAt(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.checkAccess(file, accessRead); err != nil {
+		return nil, err
+	}
+
+	file.meta.accessed(t.now())
+	return file.Content(), nil
+}
+
+/*
+Replaces the content of the file at path with data. The file is created if it does not exist yet.
+*/
+func (t *Tree) WriteFile(path string, data []byte) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "write %s", path)()
+
+	file, err := t.fileAtOrCreate(path)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkAccess(file, accessWrite); err != nil {
+		return err
+	}
+
+	file.Write(data)
+	return nil
+}
+
+/*
+Appends data to the end of the file at path. The file is created if it does not exist yet.
+*/
+func (t *Tree) AppendFile(path string, data []byte) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "append to %s", path)()
+
+	file, err := t.fileAtOrCreate(path)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkAccess(file, accessWrite); err != nil {
+		return err
+	}
+
+	file.Append(data)
+	return nil
+}
+
+/*
+Changes the size of the file at path. Unlike WriteFile and AppendFile, the file must already exist.
+*/
+func (t *Tree) Truncate(path string, size int) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.record(&err, "truncate %s to %d bytes", path, size)()
+
+	file, err := t.fileAt(path)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkAccess(file, accessWrite); err != nil {
+		return err
+	}
+
+	return file.Truncate(size)
+}
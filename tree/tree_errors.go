@@ -2,6 +2,8 @@ package tree
 
 import (
 	"fmt"
+	"io/fs"
+	"path"
 )
 
 var (
@@ -20,6 +22,52 @@ var (
 	ETIPathNotFound            = TIErrorNew(13, "the given path was not found")
 	ETICannotRemoveParent      = TIErrorNew(14, "cannot remove the parent folder non-recursively")
 	ETICannotRemoveRoot        = TIErrorNew(15, "cannot remove the root folder")
+	ETINegativeSize            = TIErrorNew(16, "a file cannot have a negative size")
+	ETICannotMoveRoot          = TIErrorNew(17, "cannot move or rename the root folder")
+	ETIMoveIntoDescendant      = TIErrorNew(18, "cannot move a folder into itself or one of its descendants")
+	ETICopyIntoDescendant      = TIErrorNew(19, "cannot copy a folder into itself or one of its descendants")
+	ETICopyFolderNotRecursive  = TIErrorNew(20, "cannot copy a folder non-recursively")
+	ETIMalformedDocument       = TIErrorNew(21, "the document does not describe a valid tree")
+	ETIUnsupportedVersion      = TIErrorNew(22, "the document format version is not supported")
+	ETIDOTSyntax               = TIErrorNew(23, "invalid graphviz DOT syntax")
+	ETIDOTNotATree             = TIErrorNew(24, "the graphviz DOT graph does not describe a tree")
+	ETIInvalidRankDir          = TIErrorNew(25, "invalid graphviz rank direction, expected TB, LR, BT or RL")
+	ETIHostDirNotEmpty         = TIErrorNew(26, "the host directory is not empty")
+	ETINotOpenForReading       = TIErrorNew(27, "the file was not opened for reading")
+	ETINotOpenForWriting       = TIErrorNew(28, "the file was not opened for writing")
+	ETIInvalidOffset           = TIErrorNew(29, "invalid file offset")
+	ETIWriteAtInAppendMode     = TIErrorNew(30, "cannot use WriteAt on a file opened with O_APPEND")
+	ETIInvalidAttribute        = TIErrorNew(31, "attribute names cannot be empty")
+	ETIPermissionDenied        = TIErrorNew(32, "permission denied")
+	ETISymlinkAsFile           = TIErrorNew(33, "cannot handle a symbolic link as a file")
+	ETISymlinkAsFolder         = TIErrorNew(34, "cannot handle a symbolic link as a folder")
+	ETINotASymlink             = TIErrorNew(35, "the node is not a symbolic link")
+	ETIEmptySymlinkTarget      = TIErrorNew(36, "a symbolic link needs a target")
+	ETISymlinkLoop             = TIErrorNew(37, "the symbolic links form a loop")
+	ETITooManySymlinks         = TIErrorNew(38, "too many levels of symbolic links")
+	ETIHardLinkToFolder        = TIErrorNew(39, "hard links can only point to files")
+	ETIBadPattern              = TIErrorNew(40, "the glob pattern is malformed")
+	ETINothingToUndo           = TIErrorNew(41, "there is nothing to undo")
+	ETINothingToRedo           = TIErrorNew(42, "there is nothing to redo")
+	ETIJournalConflict         = TIErrorNew(43, "the tree was changed outside of the journal and the operation cannot be replayed")
+	ETITransactionOpen         = TIErrorNew(44, "a transaction is open")
+	ETITransactionClosed       = TIErrorNew(45, "the transaction was already committed or rolled back")
+	ETISnapshotNotFound        = TIErrorNew(46, "there is no snapshot with the given name")
+	ETISnapshotNameNotValid    = TIErrorNew(47, "snapshot names cannot be empty")
+	ETINothingToCommit         = TIErrorNew(48, "there are no changes to commit")
+	ETIRevisionNotFound        = TIErrorNew(49, "the revision does not exist")
+	ETIAmbiguousRevision       = TIErrorNew(50, "the revision matches several commits")
+	ETIBranchExists            = TIErrorNew(51, "the branch already exists")
+	ETIBranchNameNotValid      = TIErrorNew(52, "the branch name is not valid")
+	ETIDeleteCurrentBranch     = TIErrorNew(53, "the current branch cannot be deleted")
+	ETINoCommits               = TIErrorNew(54, "there are no commits yet")
+	ETIUncommittedChanges      = TIErrorNew(55, "the tree has changes that are not committed")
+	ETIMergeInProgress         = TIErrorNew(56, "a merge is in progress, commit it once its conflicts are fixed")
+	ETIEmptyCommitMessage      = TIErrorNew(57, "the commit message cannot be empty")
+	ETIWatcherClosed           = TIErrorNew(58, "the watcher is closed")
+	ETIEventOverflow           = TIErrorNew(59, "events were dropped because the watcher did not receive them in time")
+	ETIFileTooLarge            = TIErrorNew(60, "the file would grow past the maximum file size")
+	ETITransactionConflict     = TIErrorNew(61, "the tree was changed while the transaction was open")
 )
 
 type ETreeIntrinsic struct {
@@ -35,3 +83,25 @@ func (e *ETreeIntrinsic) Error() string {
 func TIErrorNew(code int32, msg string) *ETreeIntrinsic {
 	return &ETreeIntrinsic{Code: code, Message: msg}
 }
+
+/*
+Allows errors.Is to match tree errors against the generic io/fs errors, e.g. errors.Is(ETIPathNotFound, fs.ErrNotExist) is true.
+*/
+func (e *ETreeIntrinsic) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e == ETIPathNotFound || e == ETIUnableToFollow || e == ETIChildNotFound || e == ETISnapshotNotFound || e == ETIRevisionNotFound
+	case fs.ErrExist:
+		return e == ETIDuplicatedName || e == ETIBranchExists
+	case fs.ErrInvalid:
+		return e == ETINameNotValid || e == ETINotASymlink
+	case fs.ErrClosed:
+		return e == ETIWatcherClosed
+	case path.ErrBadPattern:
+		return e == ETIBadPattern
+	case fs.ErrPermission:
+		return e == ETIPermissionDenied || e == ETIHardLinkToFolder
+	}
+
+	return false
+}
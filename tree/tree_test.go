@@ -127,3 +127,244 @@ func TestFollowPath(t *testing.T) {
 	fmt.Println(res)
 	fmt.Println(err)
 }
+
+func TestFileContent(t *testing.T) {
+	tree := CreateTree()
+	if _, err := tree.CreateFolder(".", "docs", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tree.WriteFile("docs/a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tree.AppendFile("docs/a.txt", []byte(" world")); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := tree.ReadFile("docs/a.txt")
+	if err != nil || string(content) != "hello world" {
+		t.Fatalf("unexpected content %q (%v)", content, err)
+	}
+
+	if err := tree.Truncate("docs/a.txt", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tree.Truncate("docs/a.txt", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ = tree.ReadFile("docs/a.txt")
+	if string(content) != "hel\x00\x00" {
+		t.Fatalf("unexpected content after truncate %q", content)
+	}
+
+	if _, err := tree.ReadFile("docs"); err != ETIExpectedFileFoundFolder {
+		t.Fatalf("expected ETIExpectedFileFoundFolder, got %v", err)
+	}
+
+	if err := tree.Truncate("docs/missing.txt", 0); err == nil {
+		t.Fatal("truncate should not create files")
+	}
+}
+
+func TestFollowPathForms(t *testing.T) {
+	tree := CreateTree()
+	if _, err := tree.CreateFolder("/a/b", "c", true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"a/b/c", "./a/b/c", "/a/b/c", "/a/b/c/", "/a/../a/b/./c", "/../a/b/c"} {
+		node, err := tree.FollowPath(p)
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+
+		if node.CleanName() != "c" {
+			t.Fatalf("%s: expected c, got %s", p, node.Name())
+		}
+	}
+
+	for _, p := range []string{"", ".", "/", "/a/.."} {
+		node, err := tree.FollowPath(p)
+		if err != nil || node != tree.Root() {
+			t.Fatalf("%s: expected root, got %v (%v)", p, node, err)
+		}
+	}
+
+	// the missing portion must not lose the first step that does not exist
+	if _, err := tree.CreateFolder("/a", "x/y", true); err == nil {
+		t.Fatal("names with bars should be rejected")
+	}
+
+	if _, err := tree.CreateFolder("/a/x", "y", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tree.FollowPath("/a/x/y"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveAndRename(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/a", "b", true)
+	tree.CreateFile("/a", "f.txt")
+	tree.CreateFile("/", "g.txt")
+
+	if err := tree.Move("/a", "/a/b"); err != ETIMoveIntoDescendant {
+		t.Fatalf("expected ETIMoveIntoDescendant, got %v", err)
+	}
+
+	if err := tree.Move("/g.txt", "/a/f.txt"); err != ETIDuplicatedName {
+		t.Fatalf("expected ETIDuplicatedName, got %v", err)
+	}
+
+	if err := tree.Move("/g.txt", "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := tree.FollowPath("/a/b/g.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.Parent().CleanName() != "b" {
+		t.Fatalf("parent pointer not fixed: %s", node.Parent().Name())
+	}
+
+	if err := tree.Move("/a/b", "/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.EvaluateNodePath(node) != "./c/g.txt" {
+		t.Fatalf("unexpected path %s", tree.EvaluateNodePath(node))
+	}
+
+	if err := tree.Rename("/c", "a"); err != ETIDuplicatedName {
+		t.Fatalf("expected ETIDuplicatedName, got %v", err)
+	}
+
+	if err := tree.Rename("/c/g.txt", "h.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tree.FollowPath("/c/h.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tree.Rename("/", "root"); err != ETICannotMoveRoot {
+		t.Fatalf("expected ETICannotMoveRoot, got %v", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tree := CreateTree()
+	tree.Chmod("/", 0o777)
+	tree.CreateFolder("/", "locked", false)
+	tree.WriteFile("/locked/src", []byte("src"))
+	tree.CreateFolder("/", "pub", false)
+	tree.Chmod("/pub", 0o777)
+	tree.WriteFile("/pub/dst", []byte("dst"))
+	tree.Chmod("/pub/dst", 0o666)
+	tree.CreateFolder("/pub", "full", false)
+	tree.CreateFile("/pub/full", "f")
+	tree.CreateFolder("/pub", "empty", false)
+	tree.CreateFolder("/", "full", false)
+	before := saved(t, tree)
+
+	tree.SetUser(alice)
+	failures := []struct {
+		src, dst string
+		want     error
+	}{
+		{"/locked/src", "/pub/dst", ETIPermissionDenied}, // the source cannot be taken out of /locked
+		{"/missing", "/pub/dst", ETIPathNotFound},
+		{"/pub/empty", "/pub/dst", ETIDuplicatedName},
+	}
+
+	for _, f := range failures {
+		if err := tree.Replace(f.src, f.dst); err != f.want {
+			t.Fatalf("replacing %s with %s: expected %v, got %v", f.dst, f.src, f.want, err)
+		}
+	}
+
+	tree.SetUser(RootUser)
+	if state := saved(t, tree); state != before {
+		t.Fatalf("a failed replacement changed the tree:\n%s", state)
+	}
+
+	if err := tree.Replace("/full", "/pub"); err != ETIDuplicatedName {
+		t.Fatalf("a folder with content was replaced: %v", err)
+	}
+
+	if err := tree.Replace("/locked/src", "/pub/dst"); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, _ := tree.ReadFile("/pub/dst"); string(content) != "src" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	done, _ := tree.History()
+	if _, err := tree.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if now, _ := tree.History(); len(now) != len(done)-1 {
+		t.Fatal("the replacement was not a single entry of the journal")
+	}
+
+	if state := saved(t, tree); state != before {
+		t.Fatalf("a single undo did not put both nodes back:\n%s\nwant\n%s", state, before)
+	}
+}
+
+func TestCopyAndClone(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/a", "b", true)
+	tree.WriteFile("/a/b/f.txt", []byte("data"))
+
+	if _, err := tree.Copy("/a", "/c", false); err != ETICopyFolderNotRecursive {
+		t.Fatalf("expected ETICopyFolderNotRecursive, got %v", err)
+	}
+
+	if _, err := tree.Copy("/a", "/a/b", true); err != ETICopyIntoDescendant {
+		t.Fatalf("expected ETICopyIntoDescendant, got %v", err)
+	}
+
+	copied, err := tree.Copy("/a", "/c", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if copied.CleanName() != "c" || copied.Parent() != tree.Root() {
+		t.Fatalf("unexpected copy %s", copied.Name())
+	}
+
+	file, err := tree.FollowPath("/c/b/f.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.EvaluateNodePath(file) != "./c/b/f.txt" {
+		t.Fatalf("parent pointers not rebuilt: %s", tree.EvaluateNodePath(file))
+	}
+
+	tree.WriteFile("/c/b/f.txt", []byte("changed"))
+	if content, _ := tree.ReadFile("/a/b/f.txt"); string(content) != "data" {
+		t.Fatalf("copy shares content with the original: %q", content)
+	}
+
+	clone := tree.Clone()
+	tree.RemoveFolder("/a", true)
+	if content, err := clone.ReadFile("/a/b/f.txt"); err != nil || string(content) != "data" {
+		t.Fatalf("clone was affected by the original: %q (%v)", content, err)
+	}
+
+	node, _ := clone.FollowPath("/a/b")
+	if clone.EvaluateNodePath(node) != "./a/b/" || node.Parent().Parent() != clone.Root() {
+		t.Fatal("clone parent pointers do not lead to the clone's root")
+	}
+}
@@ -0,0 +1,784 @@
+package tree
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+Repository is a version control layer over a tree, modelled after git. A commit records the state of the whole tree along with a message, its
+author and the commits it follows (its parents). Commits, and the folders and contents they are made of, are objects named by the hash of what
+they hold, so they cannot change once made and identical contents are stored once. Branches are movable names for commits, and HEAD is the
+branch being worked on (or a commit, when checked out directly).
+
+Only the names, kinds, permission bits and contents of the nodes are recorded: owners, attributes and timestamps are not, and hard links are
+recorded as independent files. Like the journal, the repository belongs to the tree and never checks the permissions of its user.
+*/
+type Repository struct {
+	tree     *Tree
+	blobs    map[Hash][]byte // contents of files and targets of links
+	folders  map[Hash][]vcsEntry
+	stored   map[Hash]Hash // the stored folder of each folder of the tree already stored, by Merkle hash (see Node.Hash)
+	commits  map[Hash]*Commit
+	order    map[Hash]int // the order commits were made in, which sorts the ones made at the same time
+	branches map[string]Hash
+	head     string // the current branch, empty when a commit is checked out directly
+	detached Hash
+	merging  Hash // the commit being merged, while the conflicts of the merge are fixed
+}
+
+/*
+The branch a new repository starts on.
+*/
+const DefaultBranch = "main"
+
+/*
+Commit is a recorded state of the tree. Tree names the root folder as it was.
+*/
+type Commit struct {
+	Hash    Hash
+	Tree    Hash
+	Parents []Hash
+	Author  string
+	Time    time.Time
+	Message string
+}
+
+/*
+BranchInfo describes a branch: its name, the commit it points to and whether it is the current one.
+*/
+type BranchInfo struct {
+	Name    string
+	Commit  Hash
+	Current bool
+}
+
+/*
+MergeResult tells what Merge did. UpToDate means there was nothing to merge, FastForward that the current branch just moved forward to the
+merged commit. Otherwise the merge either made a new commit (Commit) or left the conflicting paths for the user to fix (Conflicts).
+*/
+type MergeResult struct {
+	Commit      *Commit
+	UpToDate    bool
+	FastForward bool
+	Conflicts   []string
+}
+
+/*
+An entry of a recorded folder. Kind is 'f' for files, 'd' for folders and 'l' for links, and hash names the content of files, the target of links
+and the recorded folder of folders.
+*/
+type vcsEntry struct {
+	name string
+	kind byte
+	mode fs.FileMode
+	hash Hash
+}
+
+/*
+Returns the repository of the tree, creating it (empty, on DefaultBranch) on the first call.
+*/
+func (t *Tree) Repository() *Repository {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.repo == nil {
+		t.repo = &Repository{
+			tree:     t,
+			blobs:    map[Hash][]byte{},
+			folders:  map[Hash][]vcsEntry{},
+			stored:   map[Hash]Hash{},
+			commits:  map[Hash]*Commit{},
+			order:    map[Hash]int{},
+			branches: map[string]Hash{},
+			head:     DefaultBranch,
+		}
+	}
+
+	return t.repo
+}
+
+/*
+Records the current state of the tree as a new commit on the current branch. If a merge is in progress the commit finishes it, having the
+merged commit as its second parent. ETINothingToCommit is returned if the tree did not change since the last commit.
+*/
+func (r *Repository) Commit(message string) (*Commit, error) {
+	r.tree.mu.Lock()
+	defer r.tree.mu.Unlock()
+	return r.commit(message)
+}
+
+func (r *Repository) commit(message string) (*Commit, error) {
+	if r.tree.inTransaction() {
+		return nil, ETITransactionOpen
+	}
+
+	if strings.TrimSpace(message) == "" {
+		return nil, ETIEmptyCommitMessage
+	}
+
+	root := r.store(r.tree.Root())
+	commit := &Commit{Tree: root, Author: r.tree.user.Name, Time: r.tree.now(), Message: message}
+	if head, ok := r.headCommit(); ok {
+		if r.commits[head].Tree == root && r.merging.IsZero() {
+			return nil, ETINothingToCommit
+		}
+		commit.Parents = append(commit.Parents, head)
+	}
+
+	if !r.merging.IsZero() {
+		commit.Parents = append(commit.Parents, r.merging)
+	}
+
+	r.addCommit(commit)
+	r.moveHead(commit.Hash)
+	r.merging = Hash{}
+	return commit, nil
+}
+
+/*
+Returns the commit HEAD points to, and false if there is none yet.
+*/
+func (r *Repository) Head() (Hash, bool) {
+	r.tree.mu.RLock()
+	defer r.tree.mu.RUnlock()
+	return r.headCommit()
+}
+
+func (r *Repository) headCommit() (Hash, bool) {
+	if r.head == "" {
+		return r.detached, true
+	}
+
+	head, ok := r.branches[r.head]
+	return head, ok
+}
+
+/*
+Returns the name of the current branch, or an empty string if a commit is checked out directly.
+*/
+func (r *Repository) CurrentBranch() string {
+	r.tree.mu.RLock()
+	defer r.tree.mu.RUnlock()
+
+	return r.head
+}
+
+/*
+Returns the commit named by rev: HEAD, the name of a branch or the beginning (at least 4 characters) of the hash of a commit.
+ETIRevisionNotFound is returned if there is none and ETIAmbiguousRevision if several commits start with rev.
+*/
+func (r *Repository) Resolve(rev string) (*Commit, error) {
+	r.tree.mu.RLock()
+	defer r.tree.mu.RUnlock()
+	return r.resolve(rev)
+}
+
+func (r *Repository) resolve(rev string) (*Commit, error) {
+	if rev == "HEAD" {
+		head, ok := r.headCommit()
+		if !ok {
+			return nil, ETINoCommits
+		}
+		return r.commits[head], nil
+	}
+
+	if hash, ok := r.branches[rev]; ok {
+		return r.commits[hash], nil
+	}
+
+	var found *Commit
+	if len(rev) >= 4 {
+		for hash, commit := range r.commits {
+			if !strings.HasPrefix(hash.String(), strings.ToLower(rev)) {
+				continue
+			}
+
+			if found != nil {
+				return nil, ETIAmbiguousRevision
+			}
+			found = commit
+		}
+	}
+
+	if found == nil {
+		return nil, ETIRevisionNotFound
+	}
+
+	return found, nil
+}
+
+/*
+Returns the commit named by rev (see Resolve) and its ancestors, each commit before its parents and the newest first otherwise.
+*/
+func (r *Repository) Log(rev string) ([]Commit, error) {
+	r.tree.mu.RLock()
+	defer r.tree.mu.RUnlock()
+
+	start, err := r.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := r.ancestors(start.Hash)
+	children := map[Hash]int{}
+	for hash := range ancestors {
+		for _, parent := range r.commits[hash].Parents {
+			children[parent]++
+		}
+	}
+
+	log := []Commit{}
+	ready := []*Commit{start}
+	for len(ready) > 0 {
+		slices.SortFunc(ready, func(a, b *Commit) int {
+			return cmp.Or(a.Time.Compare(b.Time), cmp.Compare(r.order[a.Hash], r.order[b.Hash]))
+		})
+
+		commit := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		log = append(log, *commit)
+		log[len(log)-1].Parents = slices.Clone(commit.Parents)
+
+		for _, parent := range commit.Parents {
+			if children[parent]--; children[parent] == 0 {
+				ready = append(ready, r.commits[parent])
+			}
+		}
+	}
+
+	return log, nil
+}
+
+/*
+Creates the branch name pointing to the commit named by rev, or to HEAD if rev is empty. Branch names follow the rules of node names and cannot
+be HEAD.
+*/
+func (r *Repository) Branch(name string, rev string) error {
+	r.tree.mu.Lock()
+	defer r.tree.mu.Unlock()
+
+	if ValidateNodeName(name) != nil || name == "HEAD" || strings.ContainsAny(name, " \t\n") {
+		return ETIBranchNameNotValid
+	}
+
+	if _, ok := r.branches[name]; ok {
+		return ETIBranchExists
+	}
+
+	commit, err := r.resolve(cmp.Or(rev, "HEAD"))
+	if err != nil {
+		return err
+	}
+
+	r.branches[name] = commit.Hash
+	return nil
+}
+
+/*
+Deletes the branch name. The commits it pointed to are kept. The current branch cannot be deleted.
+*/
+func (r *Repository) DeleteBranch(name string) error {
+	r.tree.mu.Lock()
+	defer r.tree.mu.Unlock()
+
+	if _, ok := r.branches[name]; !ok {
+		return ETIRevisionNotFound
+	}
+
+	if name == r.head {
+		return ETIDeleteCurrentBranch
+	}
+
+	delete(r.branches, name)
+	return nil
+}
+
+/*
+Returns the branches of the repository, sorted by name.
+*/
+func (r *Repository) Branches() []BranchInfo {
+	r.tree.mu.RLock()
+	defer r.tree.mu.RUnlock()
+
+	branches := make([]BranchInfo, 0, len(r.branches))
+	for _, name := range slices.Sorted(maps.Keys(r.branches)) {
+		branches = append(branches, BranchInfo{Name: name, Commit: r.branches[name], Current: name == r.head})
+	}
+
+	return branches
+}
+
+/*
+Puts the tree in the state recorded by the commit named by rev (see Resolve). If rev is a branch it becomes the current one, otherwise (HEAD
+aside) the commit is checked out directly. The nodes that did not change keep their inodes. The journal is emptied, as its operations do not apply to the new
+state of the tree.
+
+ETIUncommittedChanges is returned if the tree changed since the last commit, unless force is set, in which case the changes are lost. force also
+abandons a merge in progress.
+*/
+func (r *Repository) Checkout(rev string, force bool) error {
+	r.tree.mu.Lock()
+	defer r.tree.mu.Unlock()
+
+	if r.tree.inTransaction() {
+		return ETITransactionOpen
+	}
+
+	commit, err := r.resolve(rev)
+	if err != nil {
+		return err
+	}
+
+	if !force && r.changed() {
+		return ETIUncommittedChanges
+	}
+
+	r.apply(commit.Tree)
+	if _, ok := r.branches[rev]; ok {
+		r.head = rev
+	} else if rev != "HEAD" {
+		r.head, r.detached = "", commit.Hash
+	}
+
+	r.merging = Hash{}
+	return nil
+}
+
+/*
+Merges the commit named by rev (see Resolve) into the current branch, as git merge does. If the current branch is behind, it just moves forward.
+Otherwise the changes made on both sides since their common ancestor are combined and recorded as a new commit. A path changed on both sides in
+different ways is a conflict: files keep both versions between conflict markers, anything else keeps the version of the current branch. The
+tree is then left with the conflicts for the user to fix and commit.
+*/
+func (r *Repository) Merge(rev string) (*MergeResult, error) {
+	r.tree.mu.Lock()
+	defer r.tree.mu.Unlock()
+
+	if r.tree.inTransaction() {
+		return nil, ETITransactionOpen
+	}
+
+	if !r.merging.IsZero() {
+		return nil, ETIMergeInProgress
+	}
+
+	ours, err := r.resolve("HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	theirs, err := r.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.changed() {
+		return nil, ETIUncommittedChanges
+	}
+
+	base := r.mergeBase(ours.Hash, theirs.Hash)
+	switch base {
+	case theirs.Hash:
+		return &MergeResult{UpToDate: true}, nil
+	case ours.Hash:
+		r.apply(theirs.Tree)
+		r.moveHead(theirs.Hash)
+		return &MergeResult{Commit: theirs, FastForward: true}, nil
+	}
+
+	baseFiles := map[string]vcsEntry{}
+	if !base.IsZero() {
+		r.flatten(r.commits[base].Tree, "/", baseFiles)
+	}
+
+	ourFiles, theirFiles := map[string]vcsEntry{}, map[string]vcsEntry{}
+	r.flatten(ours.Tree, "/", ourFiles)
+	r.flatten(theirs.Tree, "/", theirFiles)
+
+	merged, conflicts := r.mergeFiles(baseFiles, ourFiles, theirFiles, rev)
+	r.apply(r.storeFlat(merged))
+	if len(conflicts) > 0 {
+		r.merging = theirs.Hash
+		return &MergeResult{Conflicts: conflicts}, nil
+	}
+
+	r.merging = theirs.Hash
+	commit, err := r.commit(fmt.Sprintf("merge %s into %s", rev, cmp.Or(r.head, ours.Hash.Short())))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MergeResult{Commit: commit}, nil
+}
+
+/*
+Combines the paths of both sides of a merge, returning the merged paths and the ones in conflict.
+*/
+func (r *Repository) mergeFiles(base, ours, theirs map[string]vcsEntry, rev string) (map[string]vcsEntry, []string) {
+	merged := map[string]vcsEntry{}
+	conflicts := []string{}
+	paths := slices.Collect(maps.Keys(ours))
+	for p := range theirs {
+		if _, ok := ours[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	for _, p := range paths {
+		b, inBase := base[p]
+		o, inOurs := ours[p]
+		t, inTheirs := theirs[p]
+		switch {
+		case sameEntry(o, inOurs, t, inTheirs), sameEntry(b, inBase, t, inTheirs):
+			if inOurs {
+				merged[p] = o
+			}
+		case sameEntry(b, inBase, o, inOurs):
+			if inTheirs {
+				merged[p] = t
+			}
+		default:
+			conflicts = append(conflicts, p)
+			switch {
+			case inOurs && inTheirs && o.kind == 'f' && t.kind == 'f':
+				o.hash = r.storeBlob(conflictMarkers(r.blobs[o.hash], r.blobs[t.hash], rev))
+				merged[p] = o
+			case inOurs:
+				merged[p] = o
+			default:
+				merged[p] = t
+			}
+		}
+	}
+
+	// Paths kept by a conflict may be inside folders removed on the other side, which are put back. Paths inside something else than a folder
+	// are dropped.
+	for _, p := range slices.Sorted(maps.Keys(merged)) {
+		for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+			parent, ok := merged[dir]
+			if !ok {
+				parent, ok = ours[dir]
+			}
+			if !ok {
+				parent = theirs[dir]
+			}
+
+			if parent.kind != 'd' {
+				delete(merged, p)
+				if !slices.Contains(conflicts, p) {
+					conflicts = append(conflicts, p)
+				}
+				break
+			}
+			merged[dir] = parent
+		}
+	}
+
+	slices.Sort(conflicts)
+	return merged, conflicts
+}
+
+/*
+Tells whether two entries of the same path are the same, absent entries included. Folders are the same if their kind and mode are, as their
+content is merged path by path.
+*/
+func sameEntry(a vcsEntry, inA bool, b vcsEntry, inB bool) bool {
+	if !inA || !inB {
+		return inA == inB
+	}
+
+	return a.kind == b.kind && a.mode == b.mode && (a.kind == 'd' || a.hash == b.hash)
+}
+
+/*
+Returns the content of a file changed on both sides of a merge, with both versions between conflict markers.
+*/
+func conflictMarkers(ours []byte, theirs []byte, rev string) []byte {
+	var b bytes.Buffer
+	for _, part := range []struct {
+		marker  string
+		content []byte
+	}{{"<<<<<<< HEAD", ours}, {"=======", theirs}} {
+		b.WriteString(part.marker + "\n")
+		b.Write(part.content)
+		if len(part.content) > 0 && part.content[len(part.content)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteString(">>>>>>> " + rev + "\n")
+	return b.Bytes()
+}
+
+/*
+Returns the hashes of the commit and of all its ancestors.
+*/
+func (r *Repository) ancestors(hash Hash) map[Hash]bool {
+	seen := map[Hash]bool{}
+	queue := []Hash{hash}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if seen[current] {
+			continue
+		}
+
+		seen[current] = true
+		queue = append(queue, r.commits[current].Parents...)
+	}
+
+	return seen
+}
+
+/*
+Returns the closest common ancestor of two commits, or a zero hash if their histories have nothing in common.
+*/
+func (r *Repository) mergeBase(a Hash, b Hash) Hash {
+	ofA := r.ancestors(a)
+	queue := []Hash{b}
+	seen := map[Hash]bool{}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if ofA[current] {
+			return current
+		}
+
+		if !seen[current] {
+			seen[current] = true
+			queue = append(queue, r.commits[current].Parents...)
+		}
+	}
+
+	return Hash{}
+}
+
+/*
+Makes HEAD point to hash, moving the current branch if there is one.
+*/
+func (r *Repository) moveHead(hash Hash) {
+	if r.head == "" {
+		r.detached = hash
+		return
+	}
+
+	r.branches[r.head] = hash
+}
+
+/*
+Tells whether the tree changed since the commit HEAD points to. Before the first commit, any node counts as a change.
+*/
+func (r *Repository) changed() bool {
+	head, ok := r.headCommit()
+	if !ok {
+		return r.tree.Root().HasChildren()
+	}
+
+	return r.store(r.tree.Root()) != r.commits[head].Tree
+}
+
+func (r *Repository) addCommit(commit *Commit) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "commit\x00folder %s\n", commit.Tree)
+	for _, parent := range commit.Parents {
+		fmt.Fprintf(&b, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&b, "author %s %d\n\n%s", commit.Author, commit.Time.UnixNano(), commit.Message)
+
+	commit.Hash = sha256.Sum256([]byte(b.String()))
+	if _, ok := r.commits[commit.Hash]; !ok {
+		r.commits[commit.Hash] = commit
+		r.order[commit.Hash] = len(r.order)
+	}
+}
+
+/*
+Stores content as a blob, returning its hash. The content is not copied, so it must not be changed afterwards (see unshare).
+*/
+func (r *Repository) storeBlob(content []byte) Hash {
+	hash := sha256.Sum256(append([]byte(fmt.Sprintf("blob %d\x00", len(content))), content...))
+	if _, ok := r.blobs[hash]; !ok {
+		r.blobs[hash] = content
+	}
+
+	return hash
+}
+
+/*
+Stores a folder made of entries, returning its hash.
+*/
+func (r *Repository) storeFolder(entries []vcsEntry) Hash {
+	slices.SortFunc(entries, func(a, b vcsEntry) int { return cmp.Compare(a.name, b.name) })
+
+	var b strings.Builder
+	b.WriteString("folder\x00")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%c %o %s\x00%s\n", e.kind, e.mode, e.name, e.hash)
+	}
+
+	hash := sha256.Sum256([]byte(b.String()))
+	if _, ok := r.folders[hash]; !ok {
+		r.folders[hash] = entries
+	}
+
+	return hash
+}
+
+/*
+Stores folder and everything inside it, returning its hash. The contents of the files are shared with the blobs until the files change. Folders
+that did not change since they were stored are skipped, so committing only goes through the changed paths.
+*/
+func (r *Repository) store(folder *FolderNode) Hash {
+	merkle := folder.Hash()
+	if hash, ok := r.stored[merkle]; ok {
+		return hash
+	}
+
+	entries := make([]vcsEntry, 0, folder.children.len())
+	for child := range folder.children.all() {
+		entry := vcsEntry{name: child.CleanName(), mode: metaOf(child).mode}
+		switch node := child.(type) {
+		case *FolderNode:
+			entry.kind, entry.hash = 'd', r.store(node)
+		case *FileNode:
+			node.shared = true
+			entry.kind, entry.hash = 'f', r.storeBlob(node.content)
+		case *SymlinkNode:
+			entry.kind, entry.hash = 'l', r.storeBlob([]byte(node.target))
+		}
+		entries = append(entries, entry)
+	}
+
+	r.stored[merkle] = r.storeFolder(entries)
+	return r.stored[merkle]
+}
+
+/*
+Adds the entries under the stored folder hash to files, by path. dir is the path of the folder.
+*/
+func (r *Repository) flatten(hash Hash, dir string, files map[string]vcsEntry) {
+	for _, entry := range r.folders[hash] {
+		p := path.Join(dir, entry.name)
+		files[p] = entry
+		if entry.kind == 'd' {
+			r.flatten(entry.hash, p, files)
+		}
+	}
+}
+
+/*
+Stores the folders holding files, given by path (see flatten), returning the hash of the root. The hashes of the folders in files are ignored.
+*/
+func (r *Repository) storeFlat(files map[string]vcsEntry) Hash {
+	inside := map[string][]string{}
+	for p := range files {
+		inside[path.Dir(p)] = append(inside[path.Dir(p)], p)
+	}
+
+	var build func(dir string) Hash
+	build = func(dir string) Hash {
+		entries := make([]vcsEntry, 0, len(inside[dir]))
+		for _, p := range inside[dir] {
+			entry := files[p]
+			if entry.kind == 'd' {
+				entry.hash = build(p)
+			}
+			entries = append(entries, entry)
+		}
+		return r.storeFolder(entries)
+	}
+
+	return build("/")
+}
+
+/*
+Changes the tree to match the stored root folder hash and empties the journal.
+*/
+func (r *Repository) apply(hash Hash) {
+	defer r.tree.notifyUnrecorded()()
+	r.applyFolder(r.tree.Root(), r.folders[hash])
+	r.tree.journal = journal{limit: r.tree.journal.limit}
+}
+
+/*
+Changes folder to match entries (sorted by name, see storeFolder): the nodes that are not in them go away, the missing ones are created and the others are updated in place.
+*/
+func (r *Repository) applyFolder(folder *FolderNode, entries []vcsEntry) {
+	for _, child := range folder.children.slice() {
+		index, found := slices.BinarySearchFunc(entries, child.CleanName(), func(e vcsEntry, name string) int { return cmp.Compare(e.name, name) })
+		if !found || entries[index].kind != entryKind(child) {
+			folder.RemoveNode(child.CleanName())
+		}
+	}
+
+	for _, entry := range entries {
+		child := folder.childNamed(entry.name)
+		switch entry.kind {
+		case 'd':
+			if child == nil {
+				child, _ = folder.InsertFolder(entry.name)
+			}
+			r.applyFolder(child.(*FolderNode), r.folders[entry.hash])
+
+		case 'f':
+			// A hard link is replaced rather than changed, as its other names may not have the same content.
+			if file, ok := child.(*FileNode); ok && file.nlink > 1 && !bytes.Equal(file.content, r.blobs[entry.hash]) {
+				folder.RemoveNode(entry.name)
+				child = nil
+			}
+
+			if child == nil {
+				child, _ = folder.InsertFile(entry.name)
+			}
+
+			file := child.(*FileNode)
+			if content := r.blobs[entry.hash]; !bytes.Equal(file.content, content) {
+				invalidateHash(file)
+				file.content, file.shared = content, true
+				file.meta.modified(file.now())
+			}
+
+		case 'l':
+			target := string(r.blobs[entry.hash])
+			if child == nil {
+				child, _ = folder.InsertSymlink(entry.name, target)
+			}
+
+			if link := child.(*SymlinkNode); link.target != target {
+				invalidateHash(link)
+				link.target = target
+				link.meta.changed(folder.now())
+			}
+		}
+
+		if meta := metaOf(child); entry.kind != 'l' && meta.mode != entry.mode {
+			invalidateHash(child)
+			meta.mode = entry.mode
+			meta.changed(folder.now())
+		}
+	}
+}
+
+/*
+Returns the kind of n as recorded in a folder entry.
+*/
+func entryKind(n Node) byte {
+	switch n.(type) {
+	case *FolderNode:
+		return 'd'
+	case *SymlinkNode:
+		return 'l'
+	}
+
+	return 'f'
+}
@@ -0,0 +1,200 @@
+package tree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func readString(t *testing.T, tree *Tree, path string) string {
+	t.Helper()
+	content, err := tree.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCommitAndCheckout(t *testing.T) {
+	tree := CreateTree()
+	repo := tree.Repository()
+	if _, err := repo.Resolve("HEAD"); err != ETINoCommits {
+		t.Fatalf("expected no commits yet, got %v", err)
+	}
+
+	tree.CreateFolder("/", "docs", false)
+	tree.WriteFile("/docs/readme", []byte("v1"))
+	tree.Symlink("/docs/readme", "/readme")
+	first, err := repo.Commit("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Commit("again"); err != ETINothingToCommit {
+		t.Fatalf("expected nothing to commit, got %v", err)
+	}
+
+	if _, err := repo.Commit(" "); err != ETIEmptyCommitMessage {
+		t.Fatalf("expected an empty message to be refused, got %v", err)
+	}
+
+	kept := mustFollow(t, tree, "/docs").Stat().Ino()
+	tree.WriteFile("/docs/readme", []byte("v2"))
+	tree.Chmod("/docs/readme", 0o600)
+	tree.WriteFile("/docs/notes", nil)
+	second, _ := repo.Commit("second")
+
+	if err := repo.Checkout(first.Hash.Short(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readString(t, tree, "/readme"); content != "v1" {
+		t.Fatalf("the first commit was not checked out: %q", content)
+	}
+
+	if _, err := tree.FollowPath("/docs/notes"); err == nil {
+		t.Fatal("the file added later should be gone")
+	}
+
+	if mode := mustFollow(t, tree, "/docs/readme").Stat().Mode().Perm(); mode != 0o644 {
+		t.Fatalf("the mode was not checked out: %v", mode)
+	}
+
+	if ino := mustFollow(t, tree, "/docs").Stat().Ino(); ino != kept {
+		t.Fatal("unchanged folders should keep their inode")
+	}
+
+	if repo.CurrentBranch() != "" {
+		t.Fatal("checking out a commit should detach HEAD")
+	}
+
+	tree.WriteFile("/docs/readme", []byte("changed"))
+	if err := repo.Checkout(DefaultBranch, false); err != ETIUncommittedChanges {
+		t.Fatalf("expected the changes to be protected, got %v", err)
+	}
+
+	if err := repo.Checkout(DefaultBranch, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readString(t, tree, "/docs/readme"); content != "v2" || repo.CurrentBranch() != DefaultBranch {
+		t.Fatalf("expected to be back on %s, got %q", DefaultBranch, content)
+	}
+
+	log, _ := repo.Log("HEAD")
+	if len(log) != 2 || log[0].Hash != second.Hash || !reflect.DeepEqual(log[0].Parents, []Hash{first.Hash}) {
+		t.Fatalf("unexpected log %v", log)
+	}
+
+	if _, err := repo.Resolve("zzzz"); err != ETIRevisionNotFound {
+		t.Fatalf("expected an unknown revision, got %v", err)
+	}
+}
+
+func TestBranchesAndMerge(t *testing.T) {
+	tree := CreateTree()
+	repo := tree.Repository()
+	tree.WriteFile("/shared", []byte("base\n"))
+	tree.WriteFile("/gone", []byte("removed on main"))
+	tree.WriteFile("/edited", []byte("base"))
+	base, _ := repo.Commit("base")
+
+	if err := repo.Branch("feature", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Branch("feature", ""); err != ETIBranchExists {
+		t.Fatalf("expected the branch to exist, got %v", err)
+	}
+
+	if err := repo.Branch("HEAD", ""); err != ETIBranchNameNotValid {
+		t.Fatalf("expected an invalid name, got %v", err)
+	}
+
+	repo.Checkout("feature", false)
+	tree.WriteFile("/shared", []byte("theirs\n"))
+	tree.WriteFile("/added", []byte("new"))
+	tree.WriteFile("/edited", []byte("edited on feature"))
+	theirs, _ := repo.Commit("feature")
+
+	repo.Checkout(DefaultBranch, false)
+	if result, _ := repo.Merge(base.Hash.String()); !result.UpToDate {
+		t.Fatal("merging an ancestor should do nothing")
+	}
+
+	tree.WriteFile("/shared", []byte("ours\n"))
+	tree.RemoveFile("/edited")
+	ours, _ := repo.Commit("main")
+
+	result, err := repo.Merge("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Conflicts, []string{"/edited", "/shared"}) {
+		t.Fatalf("unexpected conflicts %v", result.Conflicts)
+	}
+
+	if content := readString(t, tree, "/shared"); content != "<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> feature\n" {
+		t.Fatalf("unexpected conflict markers:\n%s", content)
+	}
+
+	if content := readString(t, tree, "/added"); content != "new" {
+		t.Fatal("the file added on the branch should be merged")
+	}
+
+	if _, err := repo.Merge("feature"); err != ETIMergeInProgress {
+		t.Fatalf("expected the merge to be in progress, got %v", err)
+	}
+
+	tree.WriteFile("/shared", []byte("both\n"))
+	merge, err := repo.Commit("merge feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(merge.Parents, []Hash{ours.Hash, theirs.Hash}) {
+		t.Fatal("the merge commit should have both sides as parents")
+	}
+
+	log, _ := repo.Log(DefaultBranch)
+	if len(log) != 4 || log[0].Hash != merge.Hash || log[3].Hash != base.Hash {
+		t.Fatalf("unexpected log %v", log)
+	}
+
+	repo.Checkout("feature", false)
+	if result, _ := repo.Merge(DefaultBranch); !result.FastForward || result.Commit.Hash != merge.Hash {
+		t.Fatal("the branch should fast-forward to the merge")
+	}
+
+	if err := repo.DeleteBranch("feature"); err != ETIDeleteCurrentBranch {
+		t.Fatalf("expected the current branch to be kept, got %v", err)
+	}
+}
+
+func TestMergeWithoutConflicts(t *testing.T) {
+	tree := CreateTree()
+	repo := tree.Repository()
+	tree.CreateFolder("/", "a", false)
+	tree.WriteFile("/a/one", []byte("1"))
+	repo.Commit("base")
+	repo.Branch("other", "")
+
+	tree.WriteFile("/a/two", []byte("2"))
+	repo.Commit("two")
+
+	repo.Checkout("other", false)
+	tree.RemoveFile("/a/one")
+	tree.WriteFile("/three", []byte("3"))
+	repo.Commit("three")
+
+	result, err := repo.Merge(DefaultBranch)
+	if err != nil || result.Commit == nil || len(result.Commit.Parents) != 2 {
+		t.Fatalf("expected a merge commit, got %+v, %v", result, err)
+	}
+
+	for path, want := range map[string]bool{"/a/one": false, "/a/two": true, "/three": true} {
+		if _, err := tree.FollowPath(path); (err == nil) != want {
+			t.Fatalf("unexpected presence of %s after the merge", path)
+		}
+	}
+}
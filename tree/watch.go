@@ -0,0 +1,416 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+/*
+EventOp tells what happened to the node of an Event.
+*/
+type EventOp int
+
+const (
+	EventCreate EventOp = iota
+	EventWrite
+	EventRemove
+	EventRename
+	EventChmod
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "create"
+	case EventWrite:
+		return "write"
+	case EventRemove:
+		return "remove"
+	case EventRename:
+		return "rename"
+	case EventChmod:
+		return "chmod"
+	}
+
+	return "unknown"
+}
+
+/*
+Implements encoding.TextMarshaler, so operations are written by name in JSON.
+*/
+func (op EventOp) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+/*
+Event is a change made to the tree, as reported by a Watcher. Path is where the node is after the change, or where it was if it was removed,
+and OldPath is where a renamed (or moved) node was. Chmod covers the changes of mode, owner, group and attributes, while changes that only
+touch the timestamps are not reported.
+*/
+type Event struct {
+	Op      EventOp `json:"op"`
+	Path    string  `json:"path"`
+	OldPath string  `json:"old_path,omitempty"`
+}
+
+func (e Event) String() string {
+	if e.Op == EventRename {
+		return fmt.Sprintf("%s %s to %s", e.Op, e.OldPath, e.Path)
+	}
+
+	return fmt.Sprintf("%s %s", e.Op, e.Path)
+}
+
+/*
+The amount of events a Watcher holds until they are received. Events that come once it is full are dropped (see ETIEventOverflow).
+*/
+const WatchBufferSize = 1024
+
+/*
+Watcher reports the changes made to a node of the tree and, for folders, to what is inside them, in the spirit of fsnotify. Events are sent to
+Events, in the order the changes were made, as soon as each operation finishes. A Watcher never blocks the tree: if the receiver falls behind
+and WatchBufferSize events are pending, the next ones are dropped and ETIEventOverflow is sent to Errors.
+
+Every operation made through the Tree methods is reported, including undoing and redoing it, committing a transaction, restoring a snapshot,
+checking out a commit and loading a document, as well as the writes made through a File. An operation that fails changes nothing and reports
+nothing. As with the journal, the unchecked FolderNode and FileNode methods are not reported. A file created with some content is reported as
+created and then written. A folder created, removed or moved with its content is a single event, the nodes inside it are not reported on their own.
+
+A watch is bound to the path of the node, not to the node itself: once the node is moved away or removed, which is reported as well, the
+Watcher goes on reporting the changes made at its path, such as a new node created there.
+*/
+type Watcher struct {
+	Events <-chan Event
+	Errors <-chan error
+
+	tree      *Tree
+	path      string
+	recursive bool
+	events    chan Event
+	errors    chan error
+}
+
+/*
+Starts watching the node at path, following links. Without recursive only the node and, for a folder, its direct children are watched,
+otherwise everything under the folder is. The user must be able to read the node. The Watcher must be closed once it is no longer needed.
+*/
+func (t *Tree) Watch(path string, recursive bool) (*Watcher, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node, err := t.follow(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.checkAccess(node, accessRead); err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		tree:      t,
+		path:      absolutePath(node),
+		recursive: recursive,
+		events:    make(chan Event, WatchBufferSize),
+		errors:    make(chan error, 1),
+	}
+
+	w.Events, w.Errors = w.events, w.errors
+	t.watchers = append(t.watchers, w)
+	return w, nil
+}
+
+/*
+Returns the absolute path of the watched node, with the links on the way resolved.
+*/
+func (w *Watcher) Path() string {
+	return w.path
+}
+
+/*
+Returns true if everything under the watched folder is watched, not only its direct children.
+*/
+func (w *Watcher) Recursive() bool {
+	return w.recursive
+}
+
+/*
+Stops the watch and closes Events and Errors, once the events already sent are received. ETIWatcherClosed is returned if it was already closed.
+*/
+func (w *Watcher) Close() error {
+	w.tree.mu.Lock()
+	defer w.tree.mu.Unlock()
+
+	index := slices.Index(w.tree.watchers, w)
+	if index < 0 {
+		return ETIWatcherClosed
+	}
+
+	w.tree.watchers = slices.Delete(w.tree.watchers, index, index+1)
+	close(w.events)
+	close(w.errors)
+	return nil
+}
+
+/*
+Returns true if p is dir or is inside it.
+*/
+func withinPath(p string, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+/*
+Returns true if a node at p is watched by w.
+*/
+func (w *Watcher) watches(p string) bool {
+	if p == "" {
+		return false
+	}
+
+	if w.recursive {
+		return withinPath(p, w.path)
+	}
+	return p == w.path || path.Dir(p) == w.path
+}
+
+/*
+Returns true if e concerns w: the node is watched by it, or it is a folder holding the watched path that was removed or moved away.
+*/
+func (w *Watcher) concerns(e Event) bool {
+	switch e.Op {
+	case EventRemove:
+		return withinPath(w.path, e.Path) || w.watches(e.Path)
+	case EventRename:
+		return withinPath(w.path, e.OldPath) || w.watches(e.OldPath) || w.watches(e.Path)
+	}
+
+	return w.watches(e.Path)
+}
+
+/*
+Sends events to the watchers they concern. Called with the tree locked, which keeps Close from closing the channels meanwhile.
+*/
+func (t *Tree) notify(events []Event) {
+	for _, w := range t.watchers {
+		for _, e := range events {
+			if !w.concerns(e) {
+				continue
+			}
+
+			select {
+			case w.events <- e:
+			default:
+				select {
+				case w.errors <- ETIEventOverflow:
+				default:
+				}
+			}
+		}
+	}
+}
+
+/*
+Reports what entry did to the tree, or what undoing it did if undo is true. Called once the entry was made or replayed.
+*/
+func (t *Tree) notifyEntry(entry *journalEntry, undo bool) {
+	if len(t.watchers) > 0 {
+		t.notify(t.entryEvents(entry, undo))
+	}
+}
+
+/*
+Reports a write made to the file n through a File, which is not recorded in the journal.
+*/
+func (t *Tree) notifyWrite(n Node) {
+	if len(t.watchers) > 0 && t.attached(n) {
+		t.notify([]Event{{Op: EventWrite, Path: absolutePath(n)}})
+	}
+}
+
+/*
+Reports the changes made by an operation that is not recorded in the journal (such as Restore) by comparing the tree before and after it: the
+returned function must be called once the operation is done.
+*/
+func (t *Tree) notifyUnrecorded() func() {
+	if len(t.watchers) == 0 {
+		return func() {}
+	}
+
+	before := t.clone()
+	return func() {
+		t.notify(diffEvents(diffTrees(before, t), t))
+	}
+}
+
+/*
+Returns a single event of kind op for each node inside the root, used when the whole content of the tree is replaced.
+*/
+func (t *Tree) rootEvents(op EventOp) []Event {
+	events := make([]Event, 0, t.root.children.len())
+	for child := range t.root.children.all() {
+		events = append(events, Event{Op: op, Path: absolutePath(child)})
+	}
+
+	return events
+}
+
+/*
+Returns true if n is reachable from the root of t.
+*/
+func (t *Tree) attached(n Node) bool {
+	for current := n; current != Node(t.Root()); current = current.Parent() {
+		parent := current.Parent()
+		if parent == nil || !parent.children.contains(current) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Works out the events of entry (see notifyEntry) from its structural changes and from the states of the inodes it touched. The first change made
+to a node tells where it was before the entry, if it was in the tree at all, and the tree tells where it is now.
+*/
+func (t *Tree) entryEvents(entry *journalEntry, undo bool) []Event {
+	if entry.steps != nil {
+		entry = flattenSteps(entry.steps)
+	}
+
+	changes := slices.Clone(entry.changes)
+	if undo {
+		slices.Reverse(changes)
+	}
+
+	origins := map[Node]string{} // "" for the nodes that were not in the tree
+	order := []Node{}
+	for _, c := range changes {
+		var node Node
+		origin := ""
+		switch c := c.(type) {
+		case placement:
+			node = c.node
+			if c.attached == undo {
+				origin = path.Join(absolutePath(c.parent), c.name)
+			}
+		case renaming:
+			node, origin = c.node, path.Join(absolutePath(c.node.Parent()), c.from)
+			if undo {
+				origin = path.Join(absolutePath(c.node.Parent()), c.to)
+			}
+		}
+
+		if _, seen := origins[node]; !seen {
+			origins[node] = origin
+			order = append(order, node)
+		}
+	}
+
+	events := []Event{}
+	for _, n := range order {
+		origin, attached := origins[n], t.attached(n)
+		switch {
+		case origin == "" && attached:
+			events = append(events, Event{Op: EventCreate, Path: absolutePath(n)})
+			if file, ok := n.(*FileNode); ok && file.Size() > 0 {
+				events = append(events, Event{Op: EventWrite, Path: absolutePath(n)})
+			}
+		case origin != "" && !attached:
+			events = append(events, Event{Op: EventRemove, Path: origin})
+		case origin != "" && absolutePath(n) != origin:
+			events = append(events, Event{Op: EventRename, Path: absolutePath(n), OldPath: origin})
+		}
+	}
+
+	before, after := entry.before, entry.after
+	if undo {
+		before, after = after, before
+	}
+
+	for i, in := range entry.inodes {
+		n := entry.nodes[i]
+		if origin, seen := origins[n]; (seen && origin == "") || !t.attached(n) {
+			continue // new nodes are reported as created, along with their content
+		}
+
+		if !bytes.Equal(before[in].content, after[in].content) {
+			events = append(events, Event{Op: EventWrite, Path: absolutePath(n)})
+		}
+
+		a, b := before[in].meta, after[in].meta
+		if a.mode != b.mode || a.owner != b.owner || a.group != b.group || !maps.Equal(a.attrs, b.attrs) {
+			events = append(events, Event{Op: EventChmod, Path: absolutePath(n)})
+		}
+	}
+
+	return events
+}
+
+/*
+Merges the steps of a transaction into a single entry with all their changes, taking the states of each inode before the first step that
+touched it and after the last one. Its events are the net result of the transaction: a node created and removed inside it is not reported.
+*/
+func flattenSteps(steps []*journalEntry) *journalEntry {
+	flat := &journalEntry{before: map[*inode]inodeState{}, after: map[*inode]inodeState{}}
+	for _, step := range steps {
+		if step.steps != nil {
+			step = flattenSteps(step.steps)
+		}
+
+		flat.changes = append(flat.changes, step.changes...)
+		for i, in := range step.inodes {
+			if _, seen := flat.before[in]; !seen {
+				flat.before[in] = step.before[in]
+				flat.inodes = append(flat.inodes, in)
+				flat.nodes = append(flat.nodes, step.nodes[i])
+			}
+			flat.after[in] = step.after[in]
+		}
+	}
+
+	return flat
+}
+
+/*
+Turns the differences between two states of the tree into events, newer being the later one. The nodes inside an added or removed folder are
+dropped, as they are part of the event of the folder.
+*/
+func diffEvents(diffs []Difference, newer *Tree) []Event {
+	events := []Event{}
+	tops := map[DiffKind][]string{}
+	for _, d := range diffs {
+		switch d.Kind {
+		case DiffAdded, DiffRemoved:
+			if slices.ContainsFunc(tops[d.Kind], func(top string) bool { return withinPath(d.Path, top) }) {
+				continue
+			}
+
+			tops[d.Kind] = append(tops[d.Kind], d.Path)
+			op := EventCreate
+			if d.Kind == DiffRemoved {
+				op = EventRemove
+			}
+			events = append(events, Event{Op: op, Path: d.Path})
+			if n, err := newer.lfollow(d.Path); err == nil && op == EventCreate && n.IsFile() && n.Stat().Size() > 0 {
+				events = append(events, Event{Op: EventWrite, Path: d.Path})
+			}
+			continue
+		case DiffMoved:
+			events = append(events, Event{Op: EventRename, Path: d.Path, OldPath: d.OldPath})
+		}
+
+		if slices.Contains(d.Fields, "content") || slices.Contains(d.Fields, "target") {
+			events = append(events, Event{Op: EventWrite, Path: d.Path})
+		}
+
+		if slices.ContainsFunc(d.Fields, func(f string) bool { return f != "content" && f != "target" }) {
+			events = append(events, Event{Op: EventChmod, Path: d.Path})
+		}
+	}
+
+	return events
+}
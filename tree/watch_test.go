@@ -0,0 +1,155 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+)
+
+/*
+Returns the events pending on w, as strings.
+*/
+func drainEvents(w *Watcher) []string {
+	events := []string{}
+	for {
+		select {
+		case e := <-w.Events:
+			events = append(events, e.String())
+		default:
+			return events
+		}
+	}
+}
+
+func expectEvents(t *testing.T, w *Watcher, want ...string) {
+	t.Helper()
+	if got := drainEvents(w); !slices.Equal(got, append([]string{}, want...)) {
+		t.Fatalf("unexpected events %q, want %q", got, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	tree := CreateTree()
+	tree.CreateFolder("/src", "sub", true)
+	w, err := tree.Watch("/src", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	tree.WriteFile("/src/a", []byte("a"))
+	expectEvents(t, w, "create /src/a", "write /src/a")
+
+	tree.Chmod("/src/a", 0o600)
+	tree.Touch("/src/a")
+	tree.Rename("/src/a", "b")
+	expectEvents(t, w, "chmod /src/a", "rename /src/a to /src/b")
+
+	tree.Move("/src/b", "/src/sub")
+	tree.WriteFile("/src/sub/b", []byte("not watched"))
+	tree.CreateFile("/", "outside")
+	expectEvents(t, w, "rename /src/b to /src/sub/b")
+
+	for range 3 {
+		tree.Undo()
+	}
+	expectEvents(t, w, "rename /src/sub/b to /src/b")
+
+	if err := tree.RemoveFolder("/src/missing", true); err == nil {
+		t.Fatal("removing a missing folder should fail")
+	}
+	tree.RemoveFile("/src/b")
+	tree.Copy("/src/sub", "/src/copy", true)
+	expectEvents(t, w, "remove /src/b", "create /src/copy")
+
+	tree.Move("/src", "/moved")
+	tree.CreateFolder("/", "src", false)
+	expectEvents(t, w, "rename /src to /moved", "create /src")
+}
+
+func TestWatchUnrecordedChanges(t *testing.T) {
+	tree := CreateTree()
+	tree.WriteFile("/a", []byte("a"))
+	tree.Snapshot("before")
+	tree.Repository().Commit("first")
+
+	w, err := tree.Watch("/", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	tx, _ := tree.Begin()
+	tx.Tree().CreateFolder("/", "b", false)
+	tx.Tree().WriteFile("/b/c", []byte("c"))
+	expectEvents(t, w) // nothing happens to the tree until the transaction is committed
+	tx.Rollback()
+	expectEvents(t, w)
+
+	tx, _ = tree.Begin()
+	work := tx.Tree()
+	work.CreateFolder("/", "b", false)
+	work.WriteFile("/b/c", []byte("c"))
+	work.WriteFile("/tmp", []byte("tmp"))
+	work.Rename("/b/c", "d")
+	work.RemoveFile("/tmp")
+	expectEvents(t, w)
+	tx.Commit()
+	expectEvents(t, w, "create /b", "create /b/d", "write /b/d")
+	tree.Undo()
+	expectEvents(t, w, "remove /b/d", "remove /b")
+
+	f, _ := tree.OpenFile("/a", os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte("!"))
+	f.Close()
+	tree.CreateFolder("/d", "e", true)
+	tree.RemoveFile("/a")
+	expectEvents(t, w, "write /a", "create /d", "create /d/e", "remove /a")
+
+	tree.Restore("before")
+	expectEvents(t, w, "create /a", "write /a", "remove /d")
+
+	tree.Chmod("/a", 0o600)
+	tree.Repository().Checkout("HEAD", true)
+	expectEvents(t, w, "chmod /a", "chmod /a")
+
+	data, _ := tree.MarshalJSON()
+	tree.UnmarshalJSON(data)
+	expectEvents(t, w, "remove /a", "create /a")
+}
+
+func TestWatcherOverflowAndClose(t *testing.T) {
+	tree := CreateTree()
+	if _, err := tree.Watch("/missing", false); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("unexpected error watching a missing path: %v", err)
+	}
+
+	w, _ := tree.Watch("/", false)
+	for range WatchBufferSize + 1 {
+		tree.Touch("/a")
+		tree.RemoveFile("/a")
+	}
+
+	if err := <-w.Errors; err != ETIEventOverflow {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(drainEvents(w)) != WatchBufferSize {
+		t.Fatal("the buffer of the watcher was not filled")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, open := <-w.Events; open {
+		t.Fatal("closing the watcher did not close its events")
+	}
+
+	tree.CreateFile("/", "a")
+	if err := w.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("closing the watcher twice gave %v", err)
+	}
+}